@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"SejutaCita/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type AccessTokenHandler struct {
+	l            *log.Logger
+	accessTokens models.AccessTokenStore
+}
+
+func NewAccessTokenHandler(l *log.Logger, accessTokens models.AccessTokenStore) *AccessTokenHandler {
+	return &AccessTokenHandler{l, accessTokens}
+}
+
+// swagger:route GET /me/tokens me getAccessTokens
+// Returns the unexpired personal access tokens of the client with the date they were last used at, the most recently
+// created first
+// responses:
+//  200: accessTokensResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+func (h *AccessTokenHandler) GetAccessTokens(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accessTokens, err := h.accessTokens.GetUserAccessTokens(&ctx, ctx.Value("user_id").(string))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to get personal access tokens: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	err = accessTokens.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route POST /me/token me createAccessToken
+// Creates a personal access token for the client, limited to scopes among the permissions of the client, the token
+// is only returned in this response
+// responses:
+//  200: createdAccessTokenResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+func (h *AccessTokenHandler) CreateAccessToken(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	create := models.AccessTokenCreate{}
+	err := create.FromJSON(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
+		return
+	}
+
+	err = create.Validate()
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: err.Error()}.ToJSON(rw)
+		return
+	}
+
+	accessToken, err := models.CreateAccessToken(ctx, h.accessTokens, create)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRoleEscalation):
+			rw.WriteHeader(http.StatusForbidden)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to create personal access token: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	err = accessToken.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route DELETE /me/token me revokeAccessToken
+// Revokes a personal access token of the client
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+func (h *AccessTokenHandler) RevokeAccessToken(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := h.accessTokens.DeleteAccessToken(&ctx, ctx.Value("user_id").(string), mux.Vars(r)["id"])
+	if err != nil {
+		switch err {
+		case models.ErrAccessTokenNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to revoke personal access token: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
@@ -3,76 +3,284 @@ package handlers
 import (
 	"SejutaCita/models"
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type AuthHandler struct {
-	l *log.Logger
+	l        *log.Logger
+	store    models.UserStore
+	tokens   models.TokenStore
+	attempts models.LoginAttemptStore
 }
 
-func NewAuthHandler(l *log.Logger) *AuthHandler {
-	return &AuthHandler{l}
+func NewAuthHandler(l *log.Logger, store models.UserStore, tokens models.TokenStore, attempts models.LoginAttemptStore) *AuthHandler {
+	return &AuthHandler{l, store, tokens, attempts}
 }
 
 // swagger:route POST /login auth login
-// Login with username and password and returns the token of the user
+// Login with username and password and returns the token of the user, failed logins delay the next ones and lock
+// the username or the IP address out once too many of them fail
 // responses:
 //  200: userTokenResponse
 //  401: errorResponse
+//  429: errorResponse
 //	500: errorResponse
 func (h *AuthHandler) Login(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	user := ctx.Value(KeyUser{}).(models.User)
+	credentials := ctx.Value(KeyCredentials{}).(models.Credentials)
+	ip := clientIP(r)
 
-	existingUser, err := models.GetUserByUsername(&ctx, user.Username)
+	retryAfter, err := models.CheckLoginThrottle(h.attempts, credentials.Username, ip)
 	if err != nil {
-		if err != mongo.ErrNoDocuments {
-			rw.WriteHeader(http.StatusUnauthorized)
-			models.GenericError{Message: models.ErrIncorrectCredentials.Error()}.ToJSON(rw)
+		switch err {
+		case models.ErrLoginThrottled, models.ErrLoginLocked:
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			rw.WriteHeader(http.StatusTooManyRequests)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
 		}
+	}
+
+	existingUser, err := h.store.GetUserByUsername(&ctx, credentials.Username)
+	if err != nil && err != models.ErrUserNotFound {
 		rw.WriteHeader(http.StatusInternalServerError)
 		models.GenericError{Message: err.Error()}.ToJSON(rw)
 		return
 	}
 
-	if models.VerifyPassword(mux.Vars(r)["password"], user.Password) {
+	// unknown usernames are verified and counted as well so that neither the responses nor their timing tell which
+	// usernames exist
+	if !models.VerifyLoginPassword(credentials.Password, existingUser) {
+		err = models.RecordLoginFailure(h.attempts, credentials.Username, ip)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		}
+
 		rw.WriteHeader(http.StatusUnauthorized)
 		models.GenericError{Message: models.ErrIncorrectCredentials.Error()}.ToJSON(rw)
 		return
 	}
 
-	token, refreshToken, _ := models.GenerateAllTokens(existingUser)
-	models.UpdateAllTokens(token, refreshToken, existingUser.Id)
+	err = models.ResetLoginFailures(h.attempts, credentials.Username)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: err.Error()}.ToJSON(rw)
+		return
+	}
+
+	// a failed upgrade leaves the outdated hash in place, it is attempted again on the next login
+	err = models.UpgradePasswordHash(h.store, existingUser, credentials.Password)
+	if err != nil {
+		h.l.Printf("Unable to upgrade the password hash of user %s: %s", existingUser.Id.Hex(), err)
+	}
+
+	tokens, err := models.StartSession(h.tokens, existingUser, models.Session{
+		Device:    credentials.Device,
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to issue tokens: %s", err)}.ToJSON(rw)
+		return
+	}
 
 	rw.WriteHeader(http.StatusOK)
-	tokens := models.UserToken{
-		Token:        token,
-		RefreshToken: refreshToken,
+	tokens.ToJSON(rw)
+}
+
+// swagger:route POST /user/unlock user unlockUser
+// Unlocks a User locked out after too many failed logins and returns a boolean based on the success of the unlock,
+// the lockout of the IP address given along is lifted as well
+// responses:
+//  200: booleanResponse
+//  400: errorResponse
+//  401: errorResponse
+//	403: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+func (h *AuthHandler) UnlockUser(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ip := r.URL.Query().Get("ip")
+	if ip != "" && net.ParseIP(ip) == nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: models.ErrInvalidIP.Error()}.ToJSON(rw)
+		return
+	}
+
+	user, err := h.store.GetUserById(&ctx, mux.Vars(r)["id"])
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to get user: %s", err)}.ToJSON(rw)
+			return
+		}
 	}
+
+	err = models.ResetLoginFailures(h.attempts, user.Username)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to unlock user: %s", err)}.ToJSON(rw)
+		return
+	}
+	if ip != "" {
+		err = models.ResetIPLoginFailures(h.attempts, ip)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to unlock IP address: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
+
+// swagger:route POST /token/refresh auth refreshToken
+// Exchanges a refresh token for a new pair of tokens, the refresh token can not be used again
+// responses:
+//  200: userTokenResponse
+//  400: errorResponse
+//  401: errorResponse
+//	500: errorResponse
+func (h *AuthHandler) RefreshToken(rw http.ResponseWriter, r *http.Request) {
+	body := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
+		return
+	}
+
+	tokens, err := models.RefreshTokens(h.store, h.tokens, body.RefreshToken)
+	if err != nil {
+		if models.IsTokenError(err) || err == models.ErrRefreshTokenReused {
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to refresh tokens: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
 	tokens.ToJSON(rw)
 }
 
+// swagger:route POST /logout auth logout
+// Logs out of the current session, its access and refresh tokens can not be used again
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//  403: errorResponse
+//	500: errorResponse
+func (h *AuthHandler) Logout(rw http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("token_claims").(*models.SignedDetails)
+
+	err := models.Logout(h.tokens, claims)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to logout: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
+
+// swagger:route POST /logout/all auth logoutAll
+// Logs out of every session of the user, the tokens issued before can not be used again
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//  403: errorResponse
+//	500: errorResponse
+func (h *AuthHandler) LogoutAll(rw http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("user_id").(string)
+
+	err := models.LogoutAll(h.tokens, userId)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to logout: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
+
+// swagger:route GET /.well-known/jwks.json auth getJWKS
+// Returns the public keys verifying the tokens, identified by the kid header of the tokens
+// responses:
+//  200: jwksResponse
+//	500: errorResponse
+func (h *AuthHandler) GetJWKS(rw http.ResponseWriter, r *http.Request) {
+	jwks, err := models.GetJWKS()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to get keys: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	// verifiers may cache the keys as long as the keyring does before picking up a promoted key
+	rw.Header().Set("Cache-Control", "public, max-age=60")
+	err = jwks.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// KeyCredentials is the context key of the credentials decoded by MiddlewareValidateLogin
+type KeyCredentials struct{}
+
 func (h *AuthHandler) MiddlewareValidateLogin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		user := models.User{}
+		credentials := models.Credentials{}
 
-		err := user.FromJSON(r.Body)
+		err := credentials.FromJSON(r.Body)
 		if err != nil {
 			rw.WriteHeader(http.StatusBadRequest)
 			models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
 			return
 		}
 
-		// add the user to the context
-		ctx := context.WithValue(r.Context(), KeyUser{}, user)
+		// add the credentials to the context
+		ctx := context.WithValue(r.Context(), KeyCredentials{}, credentials)
 		r = r.WithContext(ctx)
 
 		// Call the next handler, which can be another middleware in the chain, or the final handler.
 		next.ServeHTTP(rw, r)
 	})
 }
+
+// clientIP returns the IP address of the client without the port, read from the X-Forwarded-For header when the
+// request comes through the proxies in TRUSTED_PROXIES
+func clientIP(r *http.Request) string {
+	return models.TrustedProxiesConfig().ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"SejutaCita/models"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+type AuthzHandler struct {
+	l     *log.Logger
+	roles models.RoleStore
+}
+
+func NewAuthzHandler(l *log.Logger, roles models.RoleStore) *AuthzHandler {
+	return &AuthzHandler{l, roles}
+}
+
+// swagger:route POST /authz/check authz checkAuthz
+// Decides whether the subject may perform the action on the resource under the roles and the policies in effect,
+// without performing it
+// responses:
+//  200: authzDecisionResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+func (h *AuthzHandler) CheckAuthz(rw http.ResponseWriter, r *http.Request) {
+	check := models.AuthzCheck{}
+	err := check.FromJSON(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
+		return
+	}
+
+	if !models.AllPermissions.Has(check.Action) {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: fmt.Sprintf("Unknown action: %s", check.Action)}.ToJSON(rw)
+		return
+	}
+
+	decision, err := models.CheckAuthz(h.roles, check)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to decide: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	err = decision.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
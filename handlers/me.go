@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"SejutaCita/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+type MeHandler struct {
+	l            *log.Logger
+	store        models.UserStore
+	tokens       models.TokenStore
+	attempts     models.LoginAttemptStore
+	accessTokens models.AccessTokenStore
+}
+
+func NewMeHandler(l *log.Logger, store models.UserStore, tokens models.TokenStore, attempts models.LoginAttemptStore, accessTokens models.AccessTokenStore) *MeHandler {
+	return &MeHandler{l, store, tokens, attempts, accessTokens}
+}
+
+// swagger:route GET /me me getMe
+// Returns the client's own user
+// responses:
+//  200: userSelfResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+func (h *MeHandler) GetMe(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := h.store.GetUserById(&ctx, ctx.Value("user_id").(string))
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to get user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	err = user.SelfView().ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route PATCH /me me updateMe
+// Updates the names, contact details and preferences of the client's own user and returns the updated user,
+// the roles, the attributes, the username and the password can not be changed
+// responses:
+//  200: userSelfResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  409: errorResponse
+//  500: errorResponse
+func (h *MeHandler) UpdateMe(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	profile := models.UserProfileUpdate{}
+	err := profile.FromJSON(r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrSelfServiceField):
+			rw.WriteHeader(http.StatusForbidden)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusBadRequest)
+			models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
+			return
+		}
+	}
+
+	err = profile.Validate()
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: fmt.Sprintf("Error validating user: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	user, err := h.store.UpdateProfile(&ctx, ctx.Value("user_id").(string), profile)
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		case models.ErrUserUpdateConflict:
+			rw.WriteHeader(http.StatusConflict)
+			models.GenericError{Message: models.ErrUserUpdateConflict.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to update user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	err = user.SelfView().ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route POST /me/password me changePassword
+// Changes the password of the client after checking the current one and revokes every other session and every
+// personal access token of the client, incorrect current passwords count as failed logins
+// responses:
+//  200: booleanResponse
+//  400: passwordPolicyErrorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  429: errorResponse
+//  500: errorResponse
+func (h *MeHandler) ChangePassword(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	change := models.PasswordChange{}
+	err := change.FromJSON(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
+		return
+	}
+
+	user, err := h.store.GetUserById(&ctx, ctx.Value("user_id").(string))
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to get user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	// the current password is throttled like a login so that a stolen access token can not be used to guess it
+	ip := clientIP(r)
+	retryAfter, err := models.CheckLoginThrottle(h.attempts, user.Username, ip)
+	if err != nil {
+		switch err {
+		case models.ErrLoginThrottled, models.ErrLoginLocked:
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			rw.WriteHeader(http.StatusTooManyRequests)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		}
+	}
+
+	if !models.VerifyPassword(change.CurrentPassword, user.Password) {
+		err = models.RecordLoginFailure(h.attempts, user.Username, ip)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		}
+
+		rw.WriteHeader(http.StatusForbidden)
+		models.GenericError{Message: models.ErrIncorrectPassword.Error()}.ToJSON(rw)
+		return
+	}
+
+	err = models.ResetLoginFailures(h.attempts, user.Username)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: err.Error()}.ToJSON(rw)
+		return
+	}
+
+	violations, err := models.CheckPasswordChange(change.NewPassword, user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to check password: %s", err)}.ToJSON(rw)
+		return
+	}
+	if len(violations) > 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.PasswordPolicyError{Message: models.ErrPasswordPolicy.Error(), Violations: violations}.ToJSON(rw)
+		return
+	}
+
+	_, err = h.store.UpdateUser(&ctx, user.Id.Hex(), models.User{Password: change.NewPassword})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to change password: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	claims := ctx.Value("token_claims").(*models.SignedDetails)
+	err = models.RevokeOtherSessions(h.tokens, user.Id.Hex(), claims.Family)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to revoke sessions: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	// the tokens may have been created by someone who knew the previous password
+	err = h.accessTokens.DeleteUserAccessTokens(&ctx, user.Id.Hex())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to revoke personal access tokens: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// queryString returns the value of an optional query parameter, nil when it is absent or empty
+func queryString(r *http.Request, name string) *string {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// queryTime parses an optional RFC 3339 query parameter
+func queryTime(r *http.Request, name string) (*time.Time, error) {
+	value := queryString(r, name)
+	if value == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q, must be an RFC 3339 date-time", name, *value)
+	}
+	return &t, nil
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(r *http.Request, name string) (*bool, error) {
+	value := queryString(r, name)
+	if value == nil {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(*value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q, must be true or false", name, *value)
+	}
+	return &b, nil
+}
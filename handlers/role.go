@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"SejutaCita/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type RoleHandler struct {
+	l     *log.Logger
+	roles models.RoleStore
+	store models.UserStore
+}
+
+func NewRoleHandler(l *log.Logger, roles models.RoleStore, store models.UserStore) *RoleHandler {
+	return &RoleHandler{l, roles, store}
+}
+
+// swagger:route GET /roles roles getRoles
+// Returns every role sorted by name
+// responses:
+//  200: rolesResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+func (h *RoleHandler) GetRoles(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	roles, err := h.roles.GetRoles(&ctx)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to get roles: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	err = roles.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route GET /role roles getRole
+// Returns a role by name
+// responses:
+//  200: roleResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+func (h *RoleHandler) GetRole(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	roles, err := h.roles.GetRolesByName(&ctx, []models.UserRole{models.UserRole(mux.Vars(r)["name"])})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to get role: %s", err)}.ToJSON(rw)
+		return
+	}
+	if len(roles) == 0 {
+		rw.WriteHeader(http.StatusNotFound)
+		models.GenericError{Message: models.ErrRoleNotFound.Error()}.ToJSON(rw)
+		return
+	}
+
+	err = roles[0].ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route POST /role roles createRole
+// Creates a role and returns it, a client can only grant the permissions it has
+// responses:
+//  200: roleResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  409: errorResponse
+//  500: errorResponse
+func (h *RoleHandler) CreateRole(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	role, err := models.CreateRole(ctx, h.roles, ctx.Value(KeyRole{}).(models.Role))
+	if err != nil {
+		h.writeRoleError(rw, err, "Unable to create role")
+		return
+	}
+
+	err = role.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route PUT /role roles updateRole
+// Replaces the description and the permissions of a role and returns it, a client can only grant the permissions it
+// has and the Admin role can not be changed
+// responses:
+//  200: roleResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  409: errorResponse
+//  500: errorResponse
+func (h *RoleHandler) UpdateRole(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	role := ctx.Value(KeyRole{}).(models.Role)
+	role.Name = models.UserRole(mux.Vars(r)["name"])
+
+	updated, err := models.UpdateRole(ctx, h.roles, role)
+	if err != nil {
+		h.writeRoleError(rw, err, "Unable to update role")
+		return
+	}
+
+	err = updated.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
+
+// swagger:route DELETE /role roles deleteRole
+// Deletes a role that is neither built-in nor held by any user and returns a boolean based on the success of the
+// delete
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  409: errorResponse
+//  500: errorResponse
+func (h *RoleHandler) DeleteRole(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := models.DeleteRole(ctx, h.roles, h.store, models.UserRole(mux.Vars(r)["name"]))
+	if err != nil {
+		h.writeRoleError(rw, err, "Unable to delete role")
+		return
+	}
+
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
+
+// writeRoleError writes the status matching an error raised while changing a role
+func (h *RoleHandler) writeRoleError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrRoleNotFound):
+		rw.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, models.ErrRoleEscalation):
+		rw.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, models.ErrDuplicateRole), errors.Is(err, models.ErrBuiltInRole), errors.Is(err, models.ErrRoleInUse):
+		rw.WriteHeader(http.StatusConflict)
+	default:
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("%s: %s", message, err)}.ToJSON(rw)
+		return
+	}
+	models.GenericError{Message: err.Error()}.ToJSON(rw)
+}
+
+// KeyRole is the context key of the role decoded by MiddlewareValidateRole
+type KeyRole struct{}
+
+func (h *RoleHandler) MiddlewareValidateRole(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		role := models.Role{}
+
+		err := role.FromJSON(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
+			return
+		}
+
+		// the name of an updated role comes from the query
+		if r.Method == http.MethodPut {
+			role.Name = models.UserRole(mux.Vars(r)["name"])
+		}
+
+		err = role.Validate()
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.GenericError{Message: fmt.Sprintf("Error validating role: %s", err)}.ToJSON(rw)
+			return
+		}
+
+		// add the role to the context
+		ctx := context.WithValue(r.Context(), KeyRole{}, role)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(rw, r)
+	})
+}
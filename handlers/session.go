@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"SejutaCita/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type SessionHandler struct {
+	l      *log.Logger
+	tokens models.TokenStore
+}
+
+func NewSessionHandler(l *log.Logger, tokens models.TokenStore) *SessionHandler {
+	return &SessionHandler{l, tokens}
+}
+
+// swagger:route GET /sessions sessions getSessions
+// Returns the sessions of the client, the most recently used first
+// responses:
+//  200: sessionsResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+func (h *SessionHandler) GetSessions(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	h.writeSessions(rw, r, ctx.Value("user_id").(string))
+}
+
+// swagger:route DELETE /session sessions revokeSession
+// Revokes a session of the client, clients with the sessions:revoke permission can revoke the session of any user
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+func (h *SessionHandler) RevokeSession(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// the sessions of other users are reported as not found so that their IDs can not be probed
+	session, err := h.tokens.GetSession(&ctx, mux.Vars(r)["id"])
+	if err == nil && !models.HasPermission(ctx, models.PermissionSessionsRevoke) && ctx.Value("user_id") != session.UserId {
+		err = models.ErrSessionNotFound
+	}
+	if err != nil {
+		switch err {
+		case models.ErrSessionNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to get session: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
+	err = models.RevokeSession(h.tokens, session.Id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to revoke session: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
+
+// swagger:route GET /user/sessions sessions getUserSessions
+// Returns the sessions of a user, the most recently used first
+// responses:
+//  200: sessionsResponse
+//  401: errorResponse
+//	403: errorResponse
+//  500: errorResponse
+func (h *SessionHandler) GetUserSessions(rw http.ResponseWriter, r *http.Request) {
+	h.writeSessions(rw, r, mux.Vars(r)["id"])
+}
+
+// swagger:route DELETE /user/sessions sessions revokeUserSessions
+// Revokes every session of a user
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//	403: errorResponse
+//  500: errorResponse
+func (h *SessionHandler) RevokeUserSessions(rw http.ResponseWriter, r *http.Request) {
+	err := models.RevokeUserSessions(h.tokens, mux.Vars(r)["id"])
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to revoke sessions: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.Write([]byte(strconv.FormatBool(true)))
+}
+
+// writeSessions writes the sessions of the user, flagging the one the client is authenticated with
+func (h *SessionHandler) writeSessions(rw http.ResponseWriter, r *http.Request, userId string) {
+	ctx := r.Context()
+
+	sessions, err := h.tokens.GetUserSessions(&ctx, userId)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to get sessions: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	// clients authenticated with a personal access token have no current session
+	if claims, ok := ctx.Value("token_claims").(*models.SignedDetails); ok {
+		for _, session := range sessions {
+			session.Current = session.Id == claims.Family
+		}
+	}
+
+	err = sessions.ToJSON(rw)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
+		return
+	}
+}
@@ -3,24 +3,31 @@ package handlers
 import (
 	"SejutaCita/models"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type UserHandler struct {
-	l *log.Logger
+	l            *log.Logger
+	store        models.UserStore
+	roles        models.RoleStore
+	tokens       models.TokenStore
+	accessTokens models.AccessTokenStore
 }
 
-func NewUserHandler(l *log.Logger) *UserHandler {
-	return &UserHandler{l}
+func NewUserHandler(l *log.Logger, store models.UserStore, roles models.RoleStore, tokens models.TokenStore, accessTokens models.AccessTokenStore) *UserHandler {
+	return &UserHandler{l, store, roles, tokens, accessTokens}
 }
 
 // swagger:route GET /user user getUserById
-// Returns a user by ID
+// Returns a user by ID, clients without the users:read permission can only get their own user
 // responses:
 //  200: userResponse
 //  401: errorResponse
@@ -30,15 +37,7 @@ func NewUserHandler(l *log.Logger) *UserHandler {
 func (h *UserHandler) GetUserById(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if ctx.Value("user_role") != "Admin" {
-		if ctx.Value("user_id") != mux.Vars(r)["id"] {
-			rw.WriteHeader(http.StatusForbidden)
-			models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
-			return
-		}
-	}
-
-	user, err := models.GetUserById(&ctx, mux.Vars(r)["id"])
+	user, err := h.store.GetUserById(&ctx, mux.Vars(r)["id"])
 	if err != nil {
 		switch err {
 		case models.ErrUserNotFound:
@@ -52,7 +51,7 @@ func (h *UserHandler) GetUserById(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = user.ToJSON(rw)
+	err = user.ViewFor(ctx).ToJSON(rw)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
@@ -64,6 +63,7 @@ func (h *UserHandler) GetUserById(rw http.ResponseWriter, r *http.Request) {
 // Returns all users with optional filter and sorting
 // responses:
 //  200: usersResponse
+//  400: errorResponse
 //  401: errorResponse
 //	403: errorResponse
 //  404: errorResponse
@@ -71,42 +71,33 @@ func (h *UserHandler) GetUserById(rw http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) GetUsers(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if ctx.Value("user_role") != "Admin" {
-		rw.WriteHeader(http.StatusForbidden)
-		models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
+	filter, err := usersFilterFromQuery(r)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: err.Error()}.ToJSON(rw)
 		return
 	}
 
-	filter := models.UserFilter{
-		Sort: &models.UserSort{},
-	}
-	if _, ok := mux.Vars(r)["role"]; ok {
-		if mux.Vars(r)["role"] == "Admin" {
-			admin := models.Admin
-			filter.Role = &admin
-		} else if mux.Vars(r)["role"] == "General" {
-			general := models.General
-			filter.Role = &general
-		}
-	}
-	if _, ok := mux.Vars(r)["category"]; ok {
-		if mux.Vars(r)["category"] == "first_name" {
-			filter.Sort.Category = models.FirstName
-		} else {
-			filter.Sort.Category = models.CreatedAt
-		}
+	// the roles are managed at runtime, so the role filter is checked against the existing roles
+	unknownRoles, err := models.UnknownRoles(ctx, h.roles, filter.Roles)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to get roles: %s", err)}.ToJSON(rw)
+		return
 	}
-	if _, ok := mux.Vars(r)["order"]; ok {
-		if mux.Vars(r)["order"] == strconv.Itoa(int(models.Desc)) {
-			filter.Sort.Order = models.Desc - 1
-		} else {
-			filter.Sort.Order = models.Asc - 1
-		}
+	if len(unknownRoles) > 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: fmt.Sprintf("invalid role: %q", unknownRoles[0])}.ToJSON(rw)
+		return
 	}
 
-	users, err := models.GetUsers(&ctx, &filter)
+	page, err := h.store.GetUsers(&ctx, filter)
 	if err != nil {
 		switch err {
+		case models.ErrInvalidCursor:
+			rw.WriteHeader(http.StatusBadRequest)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
 		case models.ErrUserNotFound:
 			rw.WriteHeader(http.StatusNotFound)
 			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
@@ -118,7 +109,15 @@ func (h *UserHandler) GetUsers(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = users.ToJSON(rw)
+	if page.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		rw.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+
+	err = page.AdminViews().ToJSON(rw)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		models.GenericError{Message: models.ErrJsonMarshal.Error()}.ToJSON(rw)
@@ -130,6 +129,7 @@ func (h *UserHandler) GetUsers(rw http.ResponseWriter, r *http.Request) {
 // Inserts a User in the database and returns the ID of the created User
 // responses:
 //  200: userIdResponse
+//  400: passwordPolicyErrorResponse
 //  401: errorResponse
 //	403: errorResponse
 //	409: errorResponse
@@ -137,14 +137,15 @@ func (h *UserHandler) GetUsers(rw http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) CreateUser(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if ctx.Value("user_role") != "Admin" {
-		rw.WriteHeader(http.StatusForbidden)
-		models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
+	user := r.Context().Value(KeyUser{}).(models.User)
+	if !h.checkRoles(rw, models.CheckRoleAssignment(ctx, h.roles, user.Roles)) {
+		return
+	}
+	if !h.checkPasswordPolicy(rw, user.Password, &user) {
 		return
 	}
 
-	user := r.Context().Value(KeyUser{}).(models.User)
-	id, err := models.CreateUser(&ctx, user)
+	id, err := h.store.CreateUser(&ctx, user)
 	if err != nil {
 		switch err {
 		case models.ErrDuplicateUsername:
@@ -162,31 +163,68 @@ func (h *UserHandler) CreateUser(rw http.ResponseWriter, r *http.Request) {
 }
 
 // swagger:route PUT /user user updateUser
-// Updates a User in the database and returns a boolean based on the success of the update
+// Updates a User in the database and returns a boolean based on the success of the update, a new password ends every
+// session and revokes every personal access token of the User
 // responses:
 //  200: booleanResponse
+//  400: passwordPolicyErrorResponse
 //  401: errorResponse
 //	403: errorResponse
 //  404: errorResponse
+//  409: errorResponse
 //  500: errorResponse
-func (u *UserHandler) UpdateUser(rw http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) UpdateUser(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if ctx.Value("user_role") != "Admin" {
-		rw.WriteHeader(http.StatusForbidden)
-		models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
+	user := r.Context().Value(KeyUser{}).(models.User)
+
+	existingUser, err := h.store.GetUserById(&ctx, mux.Vars(r)["id"])
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to update user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+	if !h.checkRoles(rw, models.CheckUserManagement(ctx, h.roles, existingUser)) {
+		return
+	}
+	if user.Roles != nil && !h.checkRoles(rw, models.CheckRoleAssignment(ctx, h.roles, user.Roles)) {
 		return
 	}
 
-	user := r.Context().Value(KeyUser{}).(models.User)
+	// the password is checked against the names the user will have after the update
+	if user.Password != "" {
+		if user.FirstName != "" {
+			existingUser.FirstName = user.FirstName
+		}
+		if user.MiddleName != nil {
+			existingUser.MiddleName = user.MiddleName
+		}
+		if user.LastName != nil {
+			existingUser.LastName = user.LastName
+		}
+		if !h.checkPasswordPolicy(rw, user.Password, existingUser) {
+			return
+		}
+	}
 
-	result, err := models.UpdateUser(&ctx, mux.Vars(r)["id"], user)
+	result, err := h.store.UpdateUser(&ctx, mux.Vars(r)["id"], user)
 	if err != nil {
 		switch err {
 		case models.ErrUserNotFound:
 			rw.WriteHeader(http.StatusNotFound)
 			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
 			return
+		case models.ErrUserUpdateConflict:
+			rw.WriteHeader(http.StatusConflict)
+			models.GenericError{Message: models.ErrUserUpdateConflict.Error()}.ToJSON(rw)
+			return
 		default:
 			rw.WriteHeader(http.StatusInternalServerError)
 			models.GenericError{Message: fmt.Sprintf("Unable to update user: %s", err)}.ToJSON(rw)
@@ -194,11 +232,29 @@ func (u *UserHandler) UpdateUser(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// a password reset is how a compromised account is taken back, so the previous credentials stop working
+	if user.Password != "" {
+		err = models.RevokeUserSessions(h.tokens, existingUser.Id.Hex())
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to revoke sessions: %s", err)}.ToJSON(rw)
+			return
+		}
+
+		err = h.accessTokens.DeleteUserAccessTokens(&ctx, existingUser.Id.Hex())
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to revoke personal access tokens: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
 	rw.Write([]byte(strconv.FormatBool(result)))
 }
 
 // swagger:route DELETE /user user deleteUser
-// Deletes a User in the database and returns a boolean based on the success of the update
+// Deletes a User in the database, ends every session and revokes every personal access token of the User and returns
+// a boolean based on the success of the update
 // responses:
 //  200: booleanResponse
 //  401: errorResponse
@@ -208,13 +264,24 @@ func (u *UserHandler) UpdateUser(rw http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) DeleteUser(rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if ctx.Value("user_role") != "Admin" {
-		rw.WriteHeader(http.StatusForbidden)
-		models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
+	user, err := h.store.GetUserById(&ctx, mux.Vars(r)["id"])
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to delete user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+	if !h.checkRoles(rw, models.CheckUserManagement(ctx, h.roles, user)) {
 		return
 	}
 
-	result, err := models.DeleteUser(&ctx, mux.Vars(r)["id"])
+	result, err := h.store.DeleteUser(&ctx, mux.Vars(r)["id"])
 	if err != nil {
 		switch err {
 		case models.ErrUserNotFound:
@@ -228,16 +295,248 @@ func (h *UserHandler) DeleteUser(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// the tokens issued before the deletion stay revoked once the user is restored
+	err = models.RevokeUserSessions(h.tokens, user.Id.Hex())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to revoke sessions: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	err = h.accessTokens.DeleteUserAccessTokens(&ctx, user.Id.Hex())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to revoke personal access tokens: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.Write([]byte(strconv.FormatBool(result)))
+}
+
+// swagger:route POST /user/restore user restoreUser
+// Restores a soft deleted User in the database and returns a boolean based on the success of the restore, a client
+// can only restore the users that hold no permission it lacks
+// responses:
+//  200: booleanResponse
+//  401: errorResponse
+//	403: errorResponse
+//  404: errorResponse
+//	409: errorResponse
+//  500: errorResponse
+func (h *UserHandler) RestoreUser(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := h.store.GetUserByIdIncludingDeleted(&ctx, mux.Vars(r)["id"])
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to restore user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+	if !h.checkRoles(rw, models.CheckUserManagement(ctx, h.roles, user)) {
+		return
+	}
+
+	result, err := h.store.RestoreUser(&ctx, mux.Vars(r)["id"])
+	if err != nil {
+		switch err {
+		case models.ErrUserNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			models.GenericError{Message: models.ErrUserNotFound.Error()}.ToJSON(rw)
+			return
+		case models.ErrUserNotDeleted:
+			rw.WriteHeader(http.StatusConflict)
+			models.GenericError{Message: err.Error()}.ToJSON(rw)
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.GenericError{Message: fmt.Sprintf("Unable to restore user: %s", err)}.ToJSON(rw)
+			return
+		}
+	}
+
 	rw.Write([]byte(strconv.FormatBool(result)))
 }
 
+// swagger:route POST /users/purge users purgeUsers
+// Permanently removes Users that were deleted longer ago than the retention period and returns the number of removed Users
+// responses:
+//  200: countResponse
+//  401: errorResponse
+//	403: errorResponse
+//  500: errorResponse
+func (h *UserHandler) PurgeUsers(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	count, err := h.store.PurgeUsers(&ctx, time.Now().Add(-models.UserRetention()))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to purge users: %s", err)}.ToJSON(rw)
+		return
+	}
+
+	rw.Write([]byte(strconv.FormatInt(count, 10)))
+}
+
+// usersFilterFromQuery builds the filter of GetUsers from its optional query parameters,
+// returning an error naming the first parameter with an invalid value
+func usersFilterFromQuery(r *http.Request) (*models.UserFilter, error) {
+	var err error
+	filter := &models.UserFilter{
+		Query:          r.URL.Query().Get("q"),
+		Cursor:         r.URL.Query().Get("cursor"),
+		Username:       queryString(r, "username"),
+		UsernamePrefix: queryString(r, "username_prefix"),
+		LastName:       queryString(r, "last_name"),
+		LastNamePrefix: queryString(r, "last_name_prefix"),
+	}
+
+	for _, roles := range r.URL.Query()["role"] {
+		for _, role := range strings.Split(roles, ",") {
+			if role != "" {
+				filter.Roles = append(filter.Roles, models.UserRole(role))
+			}
+		}
+	}
+
+	if sort := queryString(r, "sort"); sort != nil {
+		filter.Sort, err = models.ParseUserSort(*sort)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// the category and order parameters are the single key form of sort,
+		// searches are ranked by relevance and other lists are sorted by creation date by default
+		key := models.UserSort{Category: models.CreatedAt, Order: models.Asc}
+		if filter.Query != "" {
+			key.Category = models.Relevance
+		}
+		if category := queryString(r, "category"); category != nil {
+			var ok bool
+			key.Category, ok = models.ParseUserSortCategory(*category)
+			if !ok {
+				return nil, fmt.Errorf("invalid category: %q", *category)
+			}
+		}
+		if order := queryString(r, "order"); order != nil {
+			switch *order {
+			case strconv.Itoa(int(models.Asc)):
+				key.Order = models.Asc
+			case strconv.Itoa(int(models.Desc)):
+				key.Order = models.Desc
+			default:
+				return nil, fmt.Errorf("invalid order: %q, must be %d (ascending) or %d (descending)", *order, models.Asc, models.Desc)
+			}
+		}
+		filter.Sort = []models.UserSort{key}
+	}
+
+	if limit := queryString(r, "limit"); limit != nil {
+		filter.Limit, err = strconv.Atoi(*limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > models.MaxUsersLimit {
+			return nil, fmt.Errorf("invalid limit: %q, must be between 1 and %d", *limit, models.MaxUsersLimit)
+		}
+	}
+
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		return nil, err
+	}
+	filter.IncludeDeleted = includeDeleted != nil && *includeDeleted
+
+	timeParams := []struct {
+		name  string
+		value **time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
+	}
+	for _, param := range timeParams {
+		*param.value, err = queryTime(r, param.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	boolParams := []struct {
+		name  string
+		value **bool
+	}{
+		{"has_middle_name", &filter.HasMiddleName},
+		{"has_last_name", &filter.HasLastName},
+	}
+	for _, param := range boolParams {
+		*param.value, err = queryBool(r, param.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
 type KeyUser struct{}
 
+// checkRoles writes the error of a role check and returns false, or returns true when the check passed
+func (h *UserHandler) checkRoles(rw http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrRoleNotFound):
+		rw.WriteHeader(http.StatusBadRequest)
+		models.GenericError{Message: err.Error()}.ToJSON(rw)
+	case errors.Is(err, models.ErrRoleEscalation):
+		rw.WriteHeader(http.StatusForbidden)
+		models.GenericError{Message: err.Error()}.ToJSON(rw)
+	default:
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to check roles: %s", err)}.ToJSON(rw)
+	}
+	return false
+}
+
+// checkPasswordPolicy writes every rule of the password policy the password of the user violates and returns false,
+// or returns true when the password satisfies the policy
+func (h *UserHandler) checkPasswordPolicy(rw http.ResponseWriter, password string, user *models.User) bool {
+	violations, err := models.CheckPassword(password, user)
+	if err != nil {
+		h.l.Printf("Unable to check the password policy: %s", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.GenericError{Message: fmt.Sprintf("Unable to check password: %s", err)}.ToJSON(rw)
+		return false
+	}
+
+	if len(violations) > 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.PasswordPolicyError{Message: models.ErrPasswordPolicy.Error(), Violations: violations}.ToJSON(rw)
+		return false
+	}
+
+	return true
+}
+
 func (h *UserHandler) MiddlewareValidateUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		user := models.User{}
-
-		err := user.FromJSON(r.Body)
+		// only the fields a client can write are decoded, the others are maintained by the store
+		var user models.User
+		var err error
+		if r.Method == http.MethodPost {
+			create := models.UserCreate{}
+			err = create.FromJSON(r.Body)
+			user = create.User()
+		} else {
+			update := models.UserUpdate{}
+			err = update.FromJSON(r.Body)
+			user = update.User()
+		}
 		if err != nil {
 			rw.WriteHeader(http.StatusBadRequest)
 			models.GenericError{Message: models.ErrJsonUnmarshal.Error()}.ToJSON(rw)
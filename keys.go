@@ -0,0 +1,56 @@
+package main
+
+import (
+	"SejutaCita/models"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// errKeysUsage is returned when the keys command is run with invalid arguments
+var errKeysUsage = errors.New("usage: keys list | keys generate [RS256|ES256|EdDSA] | keys promote <kid>")
+
+// runKeysCommand lists, generates and promotes signing keys. A new key should be generated, left pending until
+// verifiers have fetched the JWKS that publishes it, then promoted to sign new tokens.
+func runKeysCommand(keys models.KeyStore, args []string) error {
+	if len(args) == 0 {
+		return errKeysUsage
+	}
+
+	switch args[0] {
+	case "list":
+		ctx := context.Background()
+		signingKeys, err := keys.GetSigningKeys(&ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KID\tALGORITHM\tSTATUS\tCREATED AT")
+		for _, key := range signingKeys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.Id, key.Algorithm, key.Status(), key.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	case "generate":
+		algorithm := models.SigningAlgorithm()
+		if len(args) > 1 {
+			algorithm = args[1]
+		}
+
+		key, err := models.GenerateSigningKey(keys, algorithm)
+		if err != nil {
+			return err
+		}
+		fmt.Println(key.Id)
+		return nil
+	case "promote":
+		if len(args) != 2 {
+			return errKeysUsage
+		}
+		return models.PromoteSigningKey(keys, args[1])
+	}
+
+	return errKeysUsage
+}
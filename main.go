@@ -22,16 +22,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
-	"github.com/go-openapi/runtime/middleware"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 
 	"SejutaCita/common"
+	"SejutaCita/middleware"
+	"SejutaCita/migrations"
+	"SejutaCita/models"
 	"SejutaCita/routes"
 )
 
@@ -42,22 +45,106 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	// initializing the database
-	common.InitDb()
-
 	// create the logger
 	l := log.New(os.Stdout, "SejutaCita: ", log.LstdFlags)
 
-	// create the router and serve the swagger documentation
+	// initializing the stores, MongoDB unless the in-memory stores are requested
+	var store models.UserStore
+	var tokens models.TokenStore
+	var keys models.KeyStore
+	var attempts models.LoginAttemptStore
+	var roles models.RoleStore
+	var accessTokens models.AccessTokenStore
+	switch os.Getenv("STORE") {
+	case "memory":
+		memoryStore := models.NewMemoryUserStore()
+		err = models.SeedUsers(memoryStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = memoryStore
+		tokens = models.NewMemoryTokenStore()
+		keys = models.NewMemoryKeyStore()
+		attempts = models.NewMemoryLoginAttemptStore()
+		roles = models.NewMemoryRoleStore()
+		accessTokens = models.NewMemoryAccessTokenStore()
+		err = models.SeedRoles(roles)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		common.InitDb()
+
+		// the private signing keys are stored sealed under the key encryption key
+		_, err = models.SigningKeyEncryptionKey()
+		if err != nil {
+			l.Fatalf("Error reading the key encryption key: %s", err)
+		}
+
+		// apply the pending database migrations on startup unless disabled, the migrate command exits once they are applied
+		migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+		if migrate || os.Getenv("MIGRATE_ON_STARTUP") != "false" {
+			err = migrations.Apply(context.Background(), common.Db, l)
+			if err != nil {
+				l.Fatalf("Error applying migrations: %s", err)
+			}
+		}
+		if migrate {
+			return
+		}
+
+		store = models.NewMongoUserStore()
+		tokens = models.NewMongoTokenStore()
+		keys = models.NewMongoKeyStore()
+		attempts = models.NewMongoLoginAttemptStore()
+		roles = models.NewMongoRoleStore()
+		accessTokens = models.NewMongoAccessTokenStore()
+
+		// the keys command manages the signing keys shared by every instance and exits
+		if len(os.Args) > 1 && os.Args[1] == "keys" {
+			err = runKeysCommand(keys, os.Args[2:])
+			if err != nil {
+				l.Fatalf("Error running the keys command: %s", err)
+			}
+			return
+		}
+	}
+
+	// generate the first signing key, instances starting together each promote one and the last promoted signs
+	err = models.EnsureSigningKey(keys, models.SigningAlgorithm())
+	if err != nil {
+		l.Fatalf("Error generating the signing key: %s", err)
+	}
+	models.UseKeyStore(keys)
+
+	// load the access policies and reload them whenever the policy file changes while the server runs
+	policies, stopPolicies := context.WithCancel(context.Background())
+	defer stopPolicies()
+	err = models.WatchPolicies(policies, models.PolicyFile(), models.PolicyReloadInterval(), l)
+	if err != nil {
+		l.Fatalf("Error loading the policies: %s", err)
+	}
+
+	// create the router, every route declares who is authorized to call it in the access table
 	r := mux.NewRouter()
-	opts := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
-	sh := middleware.Redoc(opts, nil)
-	r.Methods(http.MethodGet).Subrouter().Handle("/docs", sh)
-	r.Methods(http.MethodGet).Subrouter().Handle("/swagger.yaml", http.FileServer(http.Dir("./")))
+	table := middleware.NewAccessTable()
 
 	// add routes to the router
-	routes.AuthRoutes(r, l)
-	routes.UserRoutes(r, l)
+	routes.DocsRoutes(r, table)
+	routes.AuthRoutes(r, l, table, store, tokens, attempts)
+	routes.UserRoutes(r, l, table, store, roles, tokens, accessTokens)
+	routes.SessionRoutes(r, l, table, tokens)
+	routes.MeRoutes(r, l, table, store, tokens, attempts, accessTokens)
+	routes.RoleRoutes(r, l, table, roles, store)
+	routes.AuthzRoutes(r, l, table, roles)
+	routes.AccessTokenRoutes(r, l, table, accessTokens)
+	r.Use(table.Middleware(tokens, roles, store, accessTokens))
+
+	// refuse to start when a route does not declare its access
+	err = table.Check(r)
+	if err != nil {
+		l.Fatalf("Error checking the routes: %s", err)
+	}
 
 	// create a new server
 	s := http.Server{
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"SejutaCita/models"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Access declares who is authorized to call a route
+type Access struct {
+	// whether the route is called without authenticating the client
+	public bool
+	// the permissions the client must all be granted
+	permissions models.Permissions
+	// whether the client whose user ID is the id parameter is authorized without the permissions
+	owner bool
+	// the users the permissions are used on, matched by the resource conditions of the policies
+	resource resourceKind
+	// whether the client must authenticate with the access token of a session rather than a personal access token
+	session bool
+}
+
+// resourceKind is what a route acts on, as far as the policies are concerned
+type resourceKind int
+
+const (
+	// the route does not act on users, the policies with resource conditions do not apply
+	noResource resourceKind = iota
+	// the route acts on the user whose ID is the id parameter
+	userResource
+	// the route acts on the user whose ID is the id parameter, soft deleted or not
+	deletedUserResource
+	// the route lists users, the users hidden by the policies are filtered out by GetUsers
+	usersResource
+)
+
+// Public lets any client call the route without authenticating
+func Public() Access {
+	return Access{public: true}
+}
+
+// Authenticated lets any authenticated client call the route
+func Authenticated() Access {
+	return Access{}
+}
+
+// Permissions lets the authenticated clients granted every one of the permissions call the route
+func Permissions(permissions ...models.Permission) Access {
+	return Access{permissions: permissions}
+}
+
+// OwnerOr lets the authenticated client whose user ID is the id parameter call the route, as well as the clients
+// granted every one of the permissions
+func OwnerOr(permissions ...models.Permission) Access {
+	return Access{permissions: permissions, owner: true}
+}
+
+// OnUser makes the policies match their resource conditions against the user whose ID is the id parameter
+func (access Access) OnUser() Access {
+	access.resource = userResource
+	return access
+}
+
+// OnDeletedUser is OnUser for the routes that act on soft deleted users, such as restoring them
+func (access Access) OnDeletedUser() Access {
+	access.resource = deletedUserResource
+	return access
+}
+
+// OnUsers makes the policies that allow the permissions on some users let the client list users, GetUsers then
+// filters out the users the policies hide
+func (access Access) OnUsers() Access {
+	access.resource = usersResource
+	return access
+}
+
+// WithSession refuses the clients authenticated with a personal access token, for the routes that act on the session
+// or that must not be reachable by a leaked token
+func (access Access) WithSession() Access {
+	access.session = true
+	return access
+}
+
+// allows returns whether the authenticated client of the request is authorized, the permissions are granted by the
+// roles of the client or the policies in effect, unless a policy denies them
+func (access Access) allows(r *http.Request, users models.UserStore) (bool, error) {
+	ctx := r.Context()
+
+	// a personal access token is limited to its scopes even on its own user, so it only calls the routes that declare
+	// permissions among them
+	if scopes, ok := ctx.Value("token_scopes").(models.Permissions); ok {
+		if len(access.permissions) == 0 {
+			return false, nil
+		}
+		for _, permission := range access.permissions {
+			if !scopes.Has(permission) {
+				return false, nil
+			}
+		}
+	}
+
+	id := mux.Vars(r)["id"]
+	if access.owner && id != "" && id == ctx.Value("user_id") {
+		return true, nil
+	}
+
+	// a user that does not exist leaves only the policies without resource conditions, the handler reports it
+	var resource models.Attributes
+	if (access.resource == userResource || access.resource == deletedUserResource) && len(access.permissions) > 0 {
+		var user *models.User
+		var err error
+		if access.resource == deletedUserResource {
+			user, err = users.GetUserByIdIncludingDeleted(&ctx, id)
+		} else {
+			user, err = users.GetUserById(&ctx, id)
+		}
+		if err != nil && err != models.ErrUserNotFound {
+			return false, err
+		}
+		if user != nil {
+			resource = user.PolicyAttributes()
+		}
+	}
+
+	subject := models.SubjectFromContext(ctx)
+	policies := models.CurrentPolicies()
+	for _, permission := range access.permissions {
+		var decision models.AuthzDecision
+		if access.resource == usersResource {
+			decision = policies.DecideList(subject, permission)
+		} else {
+			decision = policies.Decide(subject, permission, resource)
+		}
+		if !decision.Allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// AccessTable holds the access declared by every route of a router
+type AccessTable struct {
+	routes map[*mux.Route]Access
+}
+
+func NewAccessTable() *AccessTable {
+	return &AccessTable{routes: map[*mux.Route]Access{}}
+}
+
+// Declare records the access of the route, it must be called before the server starts
+func (t *AccessTable) Declare(route *mux.Route, access Access) *mux.Route {
+	t.routes[route] = access
+	return route
+}
+
+// Middleware enforces the access declared by the matched route, authenticating the client through Middleware
+// unless the route is public. Routes without a declaration are refused.
+func (t *AccessTable) Middleware(tokens models.TokenStore, roles models.RoleStore, users models.UserStore, accessTokens models.AccessTokenStore) mux.MiddlewareFunc {
+	authenticate := Middleware(tokens, roles, users, accessTokens)
+
+	return func(h http.Handler) http.Handler {
+		authorized := authenticate(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			access := t.routes[mux.CurrentRoute(r)]
+			if access.session && r.Context().Value("token_claims") == nil {
+				rw.WriteHeader(http.StatusForbidden)
+				models.GenericError{Message: models.ErrSessionRequired.Error()}.ToJSON(rw)
+				return
+			}
+
+			allowed, err := access.allows(r, users)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				models.GenericError{Message: err.Error()}.ToJSON(rw)
+				return
+			}
+			if !allowed {
+				rw.WriteHeader(http.StatusForbidden)
+				models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
+				return
+			}
+
+			h.ServeHTTP(rw, r)
+		}))
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			access, ok := t.routes[mux.CurrentRoute(r)]
+			if !ok {
+				rw.WriteHeader(http.StatusForbidden)
+				models.GenericError{Message: models.ErrForbidden.Error()}.ToJSON(rw)
+				return
+			}
+
+			if access.public {
+				h.ServeHTTP(rw, r)
+				return
+			}
+			authorized.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Check returns ErrUndeclaredAccess when a route of the router that serves requests does not declare its access
+func (t *AccessTable) Check(r *mux.Router) error {
+	return r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		// the routes without a handler only group other routes
+		if route.GetHandler() == nil {
+			return nil
+		}
+		if _, ok := t.routes[route]; ok {
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			path = route.GetName()
+		}
+		methods, _ := route.GetMethods()
+		return fmt.Errorf("%w: %s %s", models.ErrUndeclaredAccess, strings.Join(methods, ","), path)
+	})
+}
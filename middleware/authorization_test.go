@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"SejutaCita/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAccessTableCheck(t *testing.T) {
+	ok := func(rw http.ResponseWriter, r *http.Request) {}
+
+	r := mux.NewRouter()
+	table := NewAccessTable()
+	table.Declare(r.Methods(http.MethodGet).Path("/declared").HandlerFunc(ok), Public())
+	// a subrouter only groups routes, it serves nothing itself
+	sub := r.PathPrefix("/group").Subrouter()
+	table.Declare(sub.Methods(http.MethodGet).Path("/declared").HandlerFunc(ok), Authenticated())
+	err := table.Check(r)
+	if err != nil {
+		t.Fatalf("checking declared routes: %v", err)
+	}
+
+	sub.Methods(http.MethodPost).Path("/undeclared").HandlerFunc(ok)
+	err = table.Check(r)
+	if !errors.Is(err, models.ErrUndeclaredAccess) {
+		t.Errorf("checking an undeclared route: got %v, want %v", err, models.ErrUndeclaredAccess)
+	}
+}
+
+func TestAccessTableRefusesUndeclaredRoutes(t *testing.T) {
+	ok := func(rw http.ResponseWriter, r *http.Request) {}
+
+	r := mux.NewRouter()
+	table := NewAccessTable()
+	table.Declare(r.Methods(http.MethodGet).Path("/public").HandlerFunc(ok), Public())
+	r.Methods(http.MethodGet).Path("/undeclared").HandlerFunc(ok)
+	r.Use(table.Middleware(models.NewMemoryTokenStore(), models.NewMemoryRoleStore(), models.NewMemoryUserStore(), models.NewMemoryAccessTokenStore()))
+
+	for path, status := range map[string]int{"/public": http.StatusOK, "/undeclared": http.StatusForbidden} {
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		if rw.Code != status {
+			t.Errorf("GET %s: got status %d, want %d", path, rw.Code, status)
+		}
+	}
+}
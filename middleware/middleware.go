@@ -5,31 +5,95 @@ import (
 	"context"
 	"net/http"
 	"strings"
+
+	"github.com/gorilla/mux"
 )
 
-func Middleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+// Middleware authenticates the client with either an access token that has not been revoked or an unexpired
+// personal access token, and resolves the permissions granted by the roles of the client
+func Middleware(tokens models.TokenStore, roles models.RoleStore, users models.UserStore, accessTokens models.AccessTokenStore) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 
-		clientToken := r.Header.Get("Authorization")
-		if !strings.Contains(clientToken, "Bearer") {
-			rw.WriteHeader(http.StatusUnauthorized)
-			models.GenericError{Message: models.ErrUnauthorized.Error()}.ToJSON(rw)
-			return
-		}
+			clientToken := r.Header.Get("Authorization")
+			if !strings.Contains(clientToken, "Bearer") {
+				rw.WriteHeader(http.StatusUnauthorized)
+				models.GenericError{Message: models.ErrUnauthorized.Error()}.ToJSON(rw)
+				return
+			}
+
+			clientToken = strings.Replace(clientToken, "Bearer ", "", -1)
+
+			var ctx context.Context
+			var err error
+			if models.IsAccessToken(clientToken) {
+				ctx, err = authenticateAccessToken(r.Context(), roles, users, accessTokens, clientToken)
+			} else {
+				ctx, err = authenticateToken(r.Context(), tokens, roles, users, clientToken)
+			}
+			if err != nil {
+				if models.IsTokenError(err) {
+					rw.WriteHeader(http.StatusUnauthorized)
+				} else {
+					rw.WriteHeader(http.StatusInternalServerError)
+				}
+				models.GenericError{Message: err.Error()}.ToJSON(rw)
+				return
+			}
+
+			h.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
 
-		clientToken = strings.Replace(clientToken, "Bearer ", "", -1)
+// authenticateToken adds the client of the JWT access token to the context, the tokens of deleted users are refused.
+// The roles and the attributes of the user are read from the store rather than the token, so that a change of either
+// applies to the permissions and the policy decisions at once.
+func authenticateToken(ctx context.Context, tokens models.TokenStore, roles models.RoleStore, users models.UserStore, clientToken string) (context.Context, error) {
+	claims, err := models.ValidateToken(tokens, clientToken)
+	if err != nil {
+		return nil, err
+	}
 
-		claims, err := models.ValidateToken(clientToken)
-		if err != nil {
-			rw.WriteHeader(http.StatusUnauthorized)
-			models.GenericError{Message: err.Error()}.ToJSON(rw)
-			return
+	user, err := users.GetUserById(&ctx, claims.UserId)
+	if err != nil {
+		if err == models.ErrUserNotFound {
+			return nil, models.ErrTokenUnknownUser
 		}
+		return nil, err
+	}
+
+	permissions, err := models.ResolvePermissions(roles, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, "user_id", claims.UserId)
+	ctx = context.WithValue(ctx, "user_roles", user.Roles)
+	ctx = context.WithValue(ctx, "user_attributes", user.Attributes)
+	ctx = context.WithValue(ctx, "user_permissions", permissions)
+	ctx = context.WithValue(ctx, "token_claims", claims)
+	return ctx, nil
+}
+
+// authenticateAccessToken adds the user of the personal access token to the context, with the current roles of the
+// user and only the permissions among the scopes of the token. There are no token claims since there is no session.
+func authenticateAccessToken(ctx context.Context, roles models.RoleStore, users models.UserStore, accessTokens models.AccessTokenStore, clientToken string) (context.Context, error) {
+	accessToken, user, err := models.AuthenticateAccessToken(accessTokens, users, clientToken)
+	if err != nil {
+		return nil, err
+	}
 
-		var ctx context.Context
-		ctx = context.WithValue(r.Context(), "user_id", claims.UserId)
-		ctx = context.WithValue(ctx, "user_role", string(claims.UserRole))
+	permissions, err := models.ResolvePermissions(roles, user.Roles)
+	if err != nil {
+		return nil, err
+	}
 
-		h.ServeHTTP(rw, r.WithContext(ctx))
-	})
+	ctx = context.WithValue(ctx, "user_id", user.Id.Hex())
+	ctx = context.WithValue(ctx, "user_roles", user.Roles)
+	ctx = context.WithValue(ctx, "user_attributes", user.Attributes)
+	ctx = context.WithValue(ctx, "user_permissions", permissions.Intersect(accessToken.Scopes))
+	ctx = context.WithValue(ctx, "token_scopes", accessToken.Scopes)
+	ctx = context.WithValue(ctx, "access_token", accessToken)
+	return ctx, nil
 }
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     1,
+		Description: "create indexes on users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "username", Value: 1}},
+					Options: options.Index().SetName("username_unique").SetUnique(true),
+				},
+				{
+					Keys:    bson.D{{Key: "role", Value: 1}},
+					Options: options.Index().SetName("role"),
+				},
+				{
+					Keys:    bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetName("created_at"),
+				},
+				{
+					Keys:    bson.D{{Key: "first_name", Value: 1}},
+					Options: options.Index().SetName("first_name_en").SetCollation(userCollation),
+				},
+			})
+			return err
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"SejutaCita/models"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errAdminPassword = errors.New("ADMIN_PASSWORD must be set to seed the admin user")
+
+func init() {
+	register(Migration{
+		Version:     2,
+		Description: "seed the Admin user",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			count, err := db.Collection("users").CountDocuments(ctx, bson.M{})
+			if err != nil || count > 0 {
+				return err
+			}
+
+			// the admin is written as users were stored at this version and later migrations bring it up to date,
+			// only its password goes through the models so that it satisfies the password policy and is hashed by
+			// the configured hasher
+			middleName := "Scarra"
+			lastName := "Lie"
+			hash, err := adminPasswordHash(&models.User{
+				FirstName:  "William",
+				MiddleName: &middleName,
+				LastName:   &lastName,
+				Username:   "admin",
+			})
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			_, err = db.Collection("users").InsertOne(ctx, bson.M{
+				"_id":         primitive.NewObjectID(),
+				"created_at":  now,
+				"updated_at":  now,
+				"deleted_at":  nil,
+				"role":        "Admin",
+				"first_name":  "William",
+				"middle_name": middleName,
+				"last_name":   lastName,
+				"username":    "admin",
+				"password":    hash,
+			})
+			// another replica may be seeding the admin concurrently, the usernames are unique
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return err
+			}
+
+			return nil
+		},
+	})
+}
+
+// adminPasswordHash returns the hash of the ADMIN_PASSWORD, which must satisfy the password policy for the admin
+func adminPasswordHash(admin *models.User) (string, error) {
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		return "", errAdminPassword
+	}
+
+	violations, err := models.CheckPassword(password, admin)
+	if err != nil {
+		return "", err
+	}
+	if len(violations) > 0 {
+		messages := make([]string, len(violations))
+		for i, violation := range violations {
+			messages[i] = violation.Message
+		}
+		return "", fmt.Errorf("ADMIN_PASSWORD violates the password policy: %s", strings.Join(messages, ", "))
+	}
+
+	return models.HashPassword(password)
+}
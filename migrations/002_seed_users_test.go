@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"SejutaCita/models"
+	"testing"
+)
+
+func TestAdminPasswordHash(t *testing.T) {
+	t.Setenv("ARGON2_MEMORY", "8192")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	admin := &models.User{FirstName: "William", Username: "admin"}
+
+	t.Setenv("ADMIN_PASSWORD", "")
+	_, err := adminPasswordHash(admin)
+	if err != errAdminPassword {
+		t.Errorf("without ADMIN_PASSWORD: got error %v", err)
+	}
+
+	t.Setenv("ADMIN_PASSWORD", "admin")
+	_, err = adminPasswordHash(admin)
+	if err == nil {
+		t.Errorf("a password violating the policy was accepted")
+	}
+
+	t.Setenv("ADMIN_PASSWORD", "Zq8#pLm2vR")
+	hash, err := adminPasswordHash(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models.PasswordNeedsRehash(hash) || !models.VerifyPassword("Zq8#pLm2vR", hash) {
+		t.Errorf("the password was not hashed by the configured hasher: %s", hash)
+	}
+}
@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+func init() {
+	register(Migration{
+		Version:     3,
+		Description: "index the search terms of users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			cur, err := db.Collection("users").Find(ctx, bson.M{})
+			if err != nil {
+				return err
+			}
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				user := searchTermsUser{}
+				err := cur.Decode(&user)
+				if err != nil {
+					return err
+				}
+
+				filter := bson.M{"_id": user.Id}
+				updater := bson.M{"$set": bson.M{"search_terms": user.searchTerms()}}
+				_, err = db.Collection("users").UpdateOne(ctx, filter, updater)
+				if err != nil {
+					return err
+				}
+			}
+			if err := cur.Err(); err != nil {
+				return err
+			}
+
+			_, err = db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "search_terms", Value: 1}},
+				Options: options.Index().SetName("search_terms"),
+			})
+			return err
+		},
+	})
+}
+
+// searchTermsUser holds the fields of a user the search terms were made of at this version, the store keeps the
+// terms up to date with its own rules afterwards
+type searchTermsUser struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	Username   string             `bson:"username"`
+	FirstName  string             `bson:"first_name"`
+	MiddleName *string            `bson:"middle_name"`
+	LastName   *string            `bson:"last_name"`
+}
+
+// searchTerms returns the normalized username and the normalized words of the names and username of the user
+func (user *searchTermsUser) searchTerms() []string {
+	normalize := func(text string) string {
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		normalized, _, err := transform.String(t, text)
+		if err != nil {
+			normalized = text
+		}
+		return strings.ToLower(normalized)
+	}
+
+	terms := []string{}
+	seen := map[string]bool{}
+	add := func(term string) {
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	add(normalize(user.Username))
+	fields := []string{user.Username, user.FirstName}
+	for _, field := range []*string{user.MiddleName, user.LastName} {
+		if field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	for _, field := range fields {
+		words := strings.FieldsFunc(normalize(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			add(word)
+		}
+	}
+
+	return terms
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     4,
+		Description: "create indexes for the sort categories of users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "updated_at", Value: 1}},
+					Options: options.Index().SetName("updated_at"),
+				},
+				{
+					Keys:    bson.D{{Key: "last_name", Value: 1}},
+					Options: options.Index().SetName("last_name_en").SetCollation(userCollation),
+				},
+				{
+					Keys:    bson.D{{Key: "username", Value: 1}},
+					Options: options.Index().SetName("username_en").SetCollation(userCollation),
+				},
+			})
+			return err
+		},
+	})
+}
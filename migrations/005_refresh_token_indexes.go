@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     5,
+		Description: "create indexes on refresh tokens",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("refresh_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "family", Value: 1}},
+					Options: options.Index().SetName("family"),
+				},
+				{
+					// expired refresh tokens can not be used anymore so MongoDB removes them
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				},
+			})
+			return err
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     7,
+		Description: "move the tokens of users to sessions",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// the single pair of tokens kept on the user is replaced by the sessions collection
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"$or": bson.A{bson.M{"token": bson.M{"$exists": true}}, bson.M{"refresh_token": bson.M{"$exists": true}}}},
+				bson.M{"$unset": bson.M{"token": "", "refresh_token": ""}},
+			)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.Collection("sessions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}},
+					Options: options.Index().SetName("user_id_last_used_at"),
+				},
+				{
+					// a session ends once its last refresh token has expired so MongoDB removes it
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				},
+			})
+			return err
+		},
+	})
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     8,
+		Description: "expire retired signing keys",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// a retired key is useless once the tokens it signed have expired so MongoDB removes it, the first key is
+			// generated on startup where the key encryption key seals it
+			_, err := db.Collection("signing_keys").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+			})
+			return err
+		},
+	})
+}
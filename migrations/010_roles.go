@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     10,
+		Description: "seed the built-in roles and give users a list of roles",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// the built-in roles as they were at this version, the existing ones are left untouched
+			now := time.Now()
+			builtIn := []struct {
+				name        string
+				description string
+				permissions bson.A
+			}{
+				{"Admin", "administers users, sessions and roles", bson.A{"users:read", "users:write", "users:delete", "sessions:read", "sessions:revoke", "roles:read", "roles:write"}},
+				{"General", "manages their own user and sessions", bson.A{}},
+			}
+			for _, role := range builtIn {
+				_, err := db.Collection("roles").UpdateOne(ctx,
+					bson.M{"_id": role.name},
+					bson.M{"$setOnInsert": bson.M{
+						"description": role.description,
+						"permissions": role.permissions,
+						"built_in":    true,
+						"created_at":  now,
+						"updated_at":  now,
+					}},
+					options.Update().SetUpsert(true),
+				)
+				// another replica may be seeding the roles concurrently
+				if err != nil && !mongo.IsDuplicateKeyError(err) {
+					return err
+				}
+			}
+
+			// the single role of every user becomes their only role and the key users are sorted on by role
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"role": bson.M{"$exists": true}},
+				mongo.Pipeline{
+					{{Key: "$set", Value: bson.M{"roles": bson.A{"$role"}, "role_key": "$role"}}},
+					{{Key: "$unset", Value: "role"}},
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			err = dropIndex(ctx, db.Collection("users"), "role")
+			if err != nil {
+				return err
+			}
+
+			_, err = db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "roles", Value: 1}},
+					Options: options.Index().SetName("roles"),
+				},
+				{
+					Keys:    bson.D{{Key: "role_key", Value: 1}},
+					Options: options.Index().SetName("role_key_en").SetCollation(userCollation),
+				},
+			})
+			return err
+		},
+	})
+}
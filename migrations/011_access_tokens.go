@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	register(Migration{
+		Version:     11,
+		Description: "create indexes on personal access tokens",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("access_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					// every request authenticated with a personal access token looks it up by its hash
+					Keys:    bson.D{{Key: "hash", Value: 1}},
+					Options: options.Index().SetName("hash").SetUnique(true),
+				},
+				{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().SetName("user_id_created_at"),
+				},
+				{
+					// expired tokens can never be used again so MongoDB removes them
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				},
+			})
+			return err
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned change to the database schema or data
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// AppliedMigration is the record of an applied migration in the schema_migrations collection
+type AppliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// registered holds every migration known to the application, added through register
+var registered []Migration
+
+func register(migration Migration) {
+	registered = append(registered, migration)
+}
+
+// Migrations returns the registered migrations ordered by version
+func Migrations() []Migration {
+	migrations := make([]Migration, len(registered))
+	copy(migrations, registered)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+// Applied returns the versions of the migrations that have already been applied to the database
+func Applied(ctx context.Context, db *mongo.Database) (map[int]AppliedMigration, error) {
+	cur, err := db.Collection("schema_migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	applied := map[int]AppliedMigration{}
+	for cur.Next(ctx) {
+		migration := AppliedMigration{}
+		err := cur.Decode(&migration)
+		if err != nil {
+			return nil, err
+		}
+		applied[migration.Version] = migration
+	}
+
+	return applied, cur.Err()
+}
+
+// Apply runs every migration that has not been applied yet in version order and records it in the
+// schema_migrations collection, stopping at the first migration that fails
+func Apply(ctx context.Context, db *mongo.Database, l *log.Logger) error {
+	applied, err := Applied(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range Migrations() {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		l.Printf("Applying migration %d: %s", migration.Version, migration.Description)
+		err = migration.Up(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		record := AppliedMigration{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+		}
+		// another replica may have applied the same migration concurrently, the migrations are idempotent
+		// so a duplicate record is not an error
+		_, err = db.Collection("schema_migrations").InsertOne(ctx, record)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// userCollation is the collation used when sorting users, indexes on sortable string fields must share it
+var userCollation = &options.Collation{Locale: "en"}
+
+// indexNotFoundCode is the code of the error MongoDB raises when dropping an index that does not exist
+const indexNotFoundCode = 27
+
+// dropIndex drops the index of the collection unless it was already dropped, so that applying the migration dropping
+// it again is not an error
+func dropIndex(ctx context.Context, collection *mongo.Collection, name string) error {
+	_, err := collection.Indexes().DropOne(ctx, name)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == indexNotFoundCode {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import "testing"
+
+func TestMigrationVersions(t *testing.T) {
+	migrations := Migrations()
+	if len(migrations) == 0 {
+		t.Fatal("no migration is registered")
+	}
+
+	// the versions follow each other so that a migration left out of the build is noticed
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d has version %d", i+1, migration.Version)
+		}
+		if migration.Description == "" || migration.Up == nil {
+			t.Errorf("migration %d has no description or no Up function", migration.Version)
+		}
+	}
+}
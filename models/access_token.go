@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The personal access tokens of the client that are returned in the response
+// swagger:response accessTokensResponse
+type accessTokensResponseWrapper struct {
+	// in:body
+	Body AccessTokens
+}
+
+// The created personal access token, the only response holding the token itself
+// swagger:response createdAccessTokenResponse
+type createdAccessTokenResponseWrapper struct {
+	// in:body
+	Body CreatedAccessToken
+}
+
+// swagger:parameters createAccessToken
+type accessTokenCreateParameterWrapper struct {
+	// The name, the scopes and the expiry of the personal access token
+	// in:body
+	// required:true
+	Body AccessTokenCreate
+}
+
+// swagger:parameters revokeAccessToken
+type accessTokenIdParameterWrapper struct {
+	// The ID of the personal access token to revoke
+	// in:query
+	// required:true
+	Id string `json:"id"`
+}
+
+// accessTokenPrefix starts every personal access token, telling them apart from JWTs
+const accessTokenPrefix = "scpat_"
+
+// accessTokenDisplayLength is how many characters of a token are kept to recognize it in the list of tokens
+const accessTokenDisplayLength = len(accessTokenPrefix) + 6
+
+// accessTokenTouchInterval is how stale the last use of a token may get before it is recorded again, so that
+// scripts calling the API in a loop do not write on every request
+const accessTokenTouchInterval = time.Minute
+
+// AccessToken defines a personal access token that scripts authenticate with instead of logging in, only the SHA-256
+// hash of the token is stored
+// swagger:model
+type AccessToken struct {
+	// the ID of the token
+	// required:true
+	Id string `bson:"_id"          json:"id"`
+	// the ID of the user the token acts as
+	UserId string `bson:"user_id"      json:"-"`
+	// the name given to the token
+	// required:true
+	Name string `bson:"name"         json:"name"`
+	// the permissions the token is limited to, among the ones granted by the roles of the user
+	// required:true
+	Scopes Permissions `bson:"scopes"       json:"scopes"`
+	// the hex encoded SHA-256 hash of the token
+	Hash string `bson:"hash"         json:"-"`
+	// the first characters of the token, to recognize it
+	// required:true
+	Prefix string `bson:"prefix"       json:"prefix"`
+	// the date the token was created at
+	// required:true
+	CreatedAt time.Time `bson:"created_at"   json:"created_at"`
+	// the date the token expires at
+	// required:true
+	ExpiresAt time.Time `bson:"expires_at"   json:"expires_at"`
+	// the date the token was last used at, null when it was never used
+	LastUsedAt *time.Time `bson:"last_used_at" json:"last_used_at"`
+}
+
+type AccessTokens []*AccessToken
+
+// AccessTokenCreate defines the personal access token requested by a user
+// swagger:model
+type AccessTokenCreate struct {
+	// the name of the token, e.g. the script or the job using it
+	// required:true
+	Name string `json:"name"`
+	// the permissions the token is limited to, at least one, the user must be granted every one of them
+	// required:true
+	Scopes Permissions `json:"scopes"`
+	// the date the token expires at, at most ACCESS_TOKEN_MAX_DAYS from now
+	// required:true
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreatedAccessToken defines a personal access token that was just created
+// swagger:model
+type CreatedAccessToken struct {
+	AccessToken
+	// the token, sent as a Bearer token, that can not be retrieved again
+	// required:true
+	Token string `json:"token"`
+}
+
+// AccessTokenStore defines the persistence operations on personal access tokens
+type AccessTokenStore interface {
+	CreateAccessToken(ctx *context.Context, token AccessToken) error
+	// GetAccessTokenByHash returns ErrAccessTokenNotFound when no token has the hash, expired tokens included
+	GetAccessTokenByHash(ctx *context.Context, hash string) (*AccessToken, error)
+	// GetUserAccessTokens returns the unexpired tokens of the user, the most recently created first
+	GetUserAccessTokens(ctx *context.Context, userId string) (AccessTokens, error)
+	TouchAccessToken(ctx *context.Context, id string, usedAt time.Time) error
+	// DeleteAccessToken returns ErrAccessTokenNotFound when the user has no token with the ID
+	DeleteAccessToken(ctx *context.Context, userId string, id string) error
+	DeleteUserAccessTokens(ctx *context.Context, userId string) error
+}
+
+// AccessTokenMaxLifetime reads from ACCESS_TOKEN_MAX_DAYS how many days a personal access token can be valid for,
+// 365 by default
+func AccessTokenMaxLifetime() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_MAX_DAYS"))
+	if err != nil || days <= 0 {
+		days = 365
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// IsAccessToken returns whether the bearer token is a personal access token rather than a JWT
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+func hashAccessToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Validate returns ErrInvalidAccessToken when the name is empty or too long, a scope is unknown or repeated, or the
+// expiry is in the past or too far away
+func (create *AccessTokenCreate) Validate() error {
+	if strings.TrimSpace(create.Name) == "" || utf8.RuneCountInString(create.Name) > 100 {
+		return fmt.Errorf("%w: the name must hold between 1 and 100 characters", ErrInvalidAccessToken)
+	}
+	// a token without scopes could not call any route
+	if len(create.Scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidAccessToken)
+	}
+	seen := map[Permission]bool{}
+	for _, scope := range create.Scopes {
+		if !AllPermissions.Has(scope) || seen[scope] {
+			return fmt.Errorf("%w: unknown or repeated scope %s", ErrInvalidAccessToken, scope)
+		}
+		seen[scope] = true
+	}
+
+	now := time.Now()
+	if !create.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: the expiry must be in the future", ErrInvalidAccessToken)
+	}
+	if create.ExpiresAt.After(now.Add(AccessTokenMaxLifetime())) {
+		return fmt.Errorf("%w: the expiry must be within %d days", ErrInvalidAccessToken, int(AccessTokenMaxLifetime().Hours()/24))
+	}
+	return nil
+}
+
+// CreateAccessToken creates a personal access token for the client in the context, limited to scopes that the client
+// is granted itself
+func CreateAccessToken(ctx context.Context, tokens AccessTokenStore, create AccessTokenCreate) (*CreatedAccessToken, error) {
+	err := checkGrantedPermissions(ctx, create.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	token := accessTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	accessToken := AccessToken{
+		Id:        primitive.NewObjectID().Hex(),
+		UserId:    ctx.Value("user_id").(string),
+		Name:      strings.TrimSpace(create.Name),
+		Scopes:    create.Scopes.sorted(),
+		Hash:      hashAccessToken(token),
+		Prefix:    token[:accessTokenDisplayLength],
+		CreatedAt: time.Now(),
+		ExpiresAt: create.ExpiresAt,
+	}
+	err = tokens.CreateAccessToken(&ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatedAccessToken{AccessToken: accessToken, Token: token}, nil
+}
+
+// AuthenticateAccessToken returns the unexpired personal access token and the user it acts as, ErrInvalidToken
+// when the token is unknown or its user was deleted and ErrExpiredToken once it has expired
+func AuthenticateAccessToken(tokens AccessTokenStore, users UserStore, token string) (*AccessToken, *User, error) {
+	ctx := context.Background()
+
+	accessToken, err := tokens.GetAccessTokenByHash(&ctx, hashAccessToken(token))
+	if err != nil {
+		if err == ErrAccessTokenNotFound {
+			return nil, nil, ErrTokenUnknownAccessToken
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	if !accessToken.ExpiresAt.After(now) {
+		return nil, nil, ErrExpiredToken
+	}
+
+	user, err := users.GetUserById(&ctx, accessToken.UserId)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, nil, ErrTokenUnknownAccessToken
+		}
+		return nil, nil, err
+	}
+
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) >= accessTokenTouchInterval {
+		err = tokens.TouchAccessToken(&ctx, accessToken.Id, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		accessToken.LastUsedAt = &now
+	}
+
+	return accessToken, user, nil
+}
+
+// Intersect returns the permissions that are also among the others
+func (permissions Permissions) Intersect(others Permissions) Permissions {
+	result := Permissions{}
+	for _, permission := range permissions {
+		if others.Has(permission) {
+			result = append(result, permission)
+		}
+	}
+	return result
+}
+
+func (create *AccessTokenCreate) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(create)
+}
+
+func (tokens AccessTokens) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, tokens)
+}
+
+// ToJSON writes the token without EncodeResponse since, like the login response, it carries the token itself
+func (token *CreatedAccessToken) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	return e.Encode(token)
+}
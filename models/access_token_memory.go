@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryAccessTokenStore is an AccessTokenStore that keeps the tokens in memory, used for tests and local demos
+type MemoryAccessTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]AccessToken
+}
+
+func NewMemoryAccessTokenStore() *MemoryAccessTokenStore {
+	return &MemoryAccessTokenStore{
+		tokens: map[string]AccessToken{},
+	}
+}
+
+func (s *MemoryAccessTokenStore) CreateAccessToken(ctx *context.Context, token AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Id] = token
+	return nil
+}
+
+func (s *MemoryAccessTokenStore) GetAccessTokenByHash(ctx *context.Context, hash string) (*AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrAccessTokenNotFound
+}
+
+func (s *MemoryAccessTokenStore) GetUserAccessTokens(ctx *context.Context, userId string) (AccessTokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tokens := AccessTokens{}
+	for id, token := range s.tokens {
+		if !token.ExpiresAt.After(now) {
+			delete(s.tokens, id)
+			continue
+		}
+		if token.UserId == userId {
+			token := token
+			tokens = append(tokens, &token)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (s *MemoryAccessTokenStore) TouchAccessToken(ctx *context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return ErrAccessTokenNotFound
+	}
+	token.LastUsedAt = &usedAt
+	s.tokens[id] = token
+
+	return nil
+}
+
+func (s *MemoryAccessTokenStore) DeleteAccessToken(ctx *context.Context, userId string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok || token.UserId != userId {
+		return ErrAccessTokenNotFound
+	}
+	delete(s.tokens, id)
+
+	return nil
+}
+
+func (s *MemoryAccessTokenStore) DeleteUserAccessTokens(ctx *context.Context, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.UserId == userId {
+			delete(s.tokens, id)
+		}
+	}
+
+	return nil
+}
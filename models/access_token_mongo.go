@@ -0,0 +1,111 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAccessTokenStore is an AccessTokenStore backed by the access_tokens collection in MongoDB
+type MongoAccessTokenStore struct{}
+
+func NewMongoAccessTokenStore() *MongoAccessTokenStore {
+	return &MongoAccessTokenStore{}
+}
+
+func (s *MongoAccessTokenStore) CreateAccessToken(ctx *context.Context, token AccessToken) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("access_tokens").InsertOne(*ctx, token)
+	return err
+}
+
+func (s *MongoAccessTokenStore) GetAccessTokenByHash(ctx *context.Context, hash string) (*AccessToken, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	token := AccessToken{}
+	err = db.Collection("access_tokens").FindOne(*ctx, bson.M{"hash": hash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrAccessTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *MongoAccessTokenStore) GetUserAccessTokens(ctx *context.Context, userId string) (AccessTokens, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the TTL monitor only runs periodically so expired tokens are filtered out explicitly
+	filter := bson.M{"user_id": userId, "expires_at": bson.M{"$gt": time.Now()}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cur, err := db.Collection("access_tokens").Find(*ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := AccessTokens{}
+	err = cur.All(*ctx, &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (s *MongoAccessTokenStore) TouchAccessToken(ctx *context.Context, id string, usedAt time.Time) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Collection("access_tokens").UpdateOne(*ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (s *MongoAccessTokenStore) DeleteAccessToken(ctx *context.Context, userId string, id string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Collection("access_tokens").DeleteOne(*ctx, bson.M{"_id": id, "user_id": userId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (s *MongoAccessTokenStore) DeleteUserAccessTokens(ctx *context.Context, userId string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("access_tokens").DeleteMany(*ctx, bson.M{"user_id": userId})
+	return err
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenCreateValidate(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_MAX_DAYS", "30")
+	valid := func() *AccessTokenCreate {
+		return &AccessTokenCreate{Name: "ci", Scopes: Permissions{PermissionUsersRead}, ExpiresAt: time.Now().Add(24 * time.Hour)}
+	}
+
+	tests := []struct {
+		name   string
+		change func(create *AccessTokenCreate)
+		valid  bool
+	}{
+		{"valid", func(create *AccessTokenCreate) {}, true},
+		{"blank name", func(create *AccessTokenCreate) { create.Name = "  " }, false},
+		{"long name", func(create *AccessTokenCreate) { create.Name = strings.Repeat("a", 101) }, false},
+		{"no scopes", func(create *AccessTokenCreate) { create.Scopes = Permissions{} }, false},
+		{"unknown scope", func(create *AccessTokenCreate) { create.Scopes = Permissions{"users:fly"} }, false},
+		{"repeated scope", func(create *AccessTokenCreate) {
+			create.Scopes = Permissions{PermissionUsersRead, PermissionUsersRead}
+		}, false},
+		{"expired", func(create *AccessTokenCreate) { create.ExpiresAt = time.Now().Add(-time.Minute) }, false},
+		{"beyond the max lifetime", func(create *AccessTokenCreate) { create.ExpiresAt = time.Now().Add(31 * 24 * time.Hour) }, false},
+	}
+	for _, test := range tests {
+		create := valid()
+		test.change(create)
+		err := create.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidAccessToken) {
+			t.Errorf("%s: got %v, want %v", test.name, err, ErrInvalidAccessToken)
+		}
+	}
+}
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	users := NewMemoryUserStore()
+	tokens := NewMemoryAccessTokenStore()
+	ctx := context.Background()
+	id, err := users.CreateUser(&ctx, User{Roles: []UserRole{General}, FirstName: "Joshua", Username: "joshua", Password: "Zq8#pLm2vR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCtx := context.WithValue(ctx, "user_id", id.Hex())
+	clientCtx = context.WithValue(clientCtx, "user_permissions", Permissions{PermissionUsersRead})
+
+	_, err = CreateAccessToken(clientCtx, tokens, AccessTokenCreate{Name: "ci", Scopes: Permissions{PermissionUsersWrite}, ExpiresAt: time.Now().Add(time.Hour)})
+	if !errors.Is(err, ErrRoleEscalation) {
+		t.Errorf("creating a token with a scope the client lacks: got %v, want %v", err, ErrRoleEscalation)
+	}
+
+	created, err := CreateAccessToken(clientCtx, tokens, AccessTokenCreate{Name: "ci", Scopes: Permissions{PermissionUsersRead}, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAccessToken(created.Token) || created.Hash == created.Token || !strings.HasPrefix(created.Token, created.Prefix) {
+		t.Errorf("the created token is not a hashed personal access token: %+v", created)
+	}
+
+	accessToken, user, err := AuthenticateAccessToken(tokens, users, created.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accessToken.Id != created.Id || user.Id != id || accessToken.LastUsedAt == nil {
+		t.Errorf("authenticating the token: got %+v of %s", accessToken, user.Id.Hex())
+	}
+
+	_, _, err = AuthenticateAccessToken(tokens, users, created.Token+"x")
+	if err != ErrTokenUnknownAccessToken {
+		t.Errorf("authenticating an unknown token: got %v, want %v", err, ErrTokenUnknownAccessToken)
+	}
+
+	_, err = users.DeleteUser(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = AuthenticateAccessToken(tokens, users, created.Token)
+	if err != ErrTokenUnknownAccessToken {
+		t.Errorf("authenticating the token of a deleted user: got %v, want %v", err, ErrTokenUnknownAccessToken)
+	}
+}
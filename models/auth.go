@@ -1,19 +1,12 @@
 package models
 
 import (
-	"SejutaCita/common"
 	"context"
 	"encoding/json"
 	"io"
-	"log"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Tokens that are returned in the response
@@ -32,17 +25,48 @@ type loginParameterWrapper struct {
 		Username string
 		// required:true
 		Password string
+		// The label of the device, listed in the sessions of the user
+		Device string
 	}
 }
 
+// swagger:parameters refreshToken
+type refreshTokenParameterWrapper struct {
+	// The refresh token returned by the login or the previous refresh
+	// in:body
+	Body struct {
+		// required:true
+		RefreshToken string `json:"refresh_token"`
+	}
+}
+
+// Credentials are the details sent by the client to login
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// the label of the device the client logs in from, listed in the sessions of the user
+	Device string `json:"device"`
+}
+
+func (credentials *Credentials) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(credentials)
+}
+
 type UserToken struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 }
 
 type SignedDetails struct {
-	UserId   string
-	UserRole UserRole
+	// the type of the token, a token of one type is never accepted as another
+	Type   TokenType `json:"typ"`
+	UserId string
+	// the names of the roles of the user when the token was issued, for other services, the API reads the current
+	// roles of the user from the store on every request
+	UserRoles []UserRole `json:",omitempty"`
+	// the ID of the session the token was issued with, the family of its refresh tokens
+	Family string `json:",omitempty"`
 	jwt.StandardClaims
 }
 
@@ -51,173 +75,147 @@ func (tokens *UserToken) ToJSON(w io.Writer) error {
 	return e.Encode(tokens)
 }
 
-func HashAndSalt(password string) string {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+func GenerateAllTokens(user *User, family string) (signedToken string, signedRefreshToken string, refreshClaims *SignedDetails, err error) {
+	signedToken, _, err = IssueToken(TokenTypeAccess, &SignedDetails{
+		UserId:    user.Id.Hex(),
+		UserRoles: user.Roles,
+		Family:    family,
+	})
 	if err != nil {
-		log.Println(err)
+		return
 	}
-	return string(hash)
+
+	signedRefreshToken, refreshClaims, err = IssueToken(TokenTypeRefresh, &SignedDetails{
+		UserId: user.Id.Hex(),
+		Family: family,
+	})
+	return
 }
 
-func VerifyPassword(plainPassword string, hashedPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
+// IssueTokens generates a new pair of tokens for the user and records the refresh token in the family of the session
+func IssueTokens(tokens TokenStore, user *User, sessionId string) (*UserToken, error) {
+	signedToken, signedRefreshToken, refreshClaims, err := GenerateAllTokens(user, sessionId)
 	if err != nil {
-		log.Println(err)
-		return false
+		return nil, err
 	}
 
-	return true
-}
-
-func CreateToken(userId string) (string, error) {
-	atClaims := jwt.MapClaims{}
-	atClaims["authorized"] = true
-	atClaims["user_id"] = userId
-	atClaims["exp"] = time.Now().Add(time.Minute * 15).Unix()
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
-	token, err := at.SignedString([]byte(os.Getenv("ACCESS_SECRET")))
+	ctx := context.Background()
+	err = tokens.CreateRefreshToken(&ctx, RefreshToken{
+		Id:        refreshClaims.Id,
+		Family:    sessionId,
+		UserId:    user.Id.Hex(),
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Unix(refreshClaims.ExpiresAt, 0),
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return token, nil
+
+	return &UserToken{
+		Token:        signedToken,
+		RefreshToken: signedRefreshToken,
+	}, nil
 }
 
-func GenerateAllTokens(user *User) (signedToken string, signedRefreshToken string, err error) {
-	claims := &SignedDetails{
-		UserId:   user.Id.Hex(),
-		UserRole: user.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * time.Duration(24)).Unix(),
-		},
+// ValidateToken returns the claims of a valid access token that has not been revoked,
+// refresh tokens are only accepted by RefreshTokens
+func ValidateToken(tokens TokenStore, signedToken string) (claims *SignedDetails, err error) {
+	claims, err = ParseToken(TokenTypeAccess, signedToken)
+	if err != nil {
+		return nil, err
 	}
 
-	refreshClaims := &SignedDetails{
-		UserId: user.Id.Hex(),
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * time.Duration(168)).Unix(),
-		},
+	if len(claims.UserRoles) == 0 || claims.Family == "" {
+		return nil, ErrTokenMissingClaim
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("SECRET_KEY")))
+	ctx := context.Background()
+	revocations, err := tokens.GetRevocations(&ctx, []string{sessionRevocationId(claims.Family), userRevocationId(claims.UserId)})
 	if err != nil {
-		log.Panic(err)
-		return
+		return nil, err
 	}
-
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(os.Getenv("SECRET_KEY")))
-	if err != nil {
-		log.Panic(err)
-		return
+	for _, revocation := range revocations {
+		// the issue time only has a precision of seconds, tokens issued in the second of a revocation of every
+		// token of the user are still accepted so that logging in right after it works
+		if revocation.Id == sessionRevocationId(claims.Family) || claims.IssuedAt < revocation.RevokedAt.Unix() {
+			return nil, ErrRevokedToken
+		}
 	}
 
-	return token, refreshToken, err
+	return claims, nil
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId primitive.ObjectID) {
-	db, err := common.GetDb()
-	if err != nil {
-		log.Panic(err)
-		return
-	}
-
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+// Logout ends the session the access token was issued with
+func Logout(tokens TokenStore, claims *SignedDetails) error {
+	return RevokeSession(tokens, claims.Family)
+}
 
-	filter := bson.M{"_id": userId}
+// LogoutAll ends every session of the user
+func LogoutAll(tokens TokenStore, userId string) error {
+	return RevokeUserSessions(tokens, userId)
+}
 
-	updater := bson.M{
-		"$set": bson.M{
-			"token":         signedToken,
-			"refresh_token": signedRefreshToken,
-			"updated_at":    time.Now(),
-		},
+// RefreshTokens exchanges a refresh token for a new pair of tokens of the same family. Every refresh token can
+// only be used once, presenting a used one again ends the session of its family since the token must have leaked.
+func RefreshTokens(users UserStore, tokens TokenStore, signedRefreshToken string) (*UserToken, error) {
+	claims, err := ParseToken(TokenTypeRefresh, signedRefreshToken)
+	if err != nil {
+		return nil, err
 	}
-
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	if claims.Family == "" {
+		return nil, ErrTokenMissingClaim
 	}
 
-	_, err = db.Collection("users").UpdateOne(
-		ctx,
-		filter,
-		updater,
-		&opt,
-	)
-	defer cancel()
-
+	// a refresh token issued before the user logged out everywhere may still be unused
+	ctx := context.Background()
+	revocations, err := tokens.GetRevocations(&ctx, []string{userRevocationId(claims.UserId)})
 	if err != nil {
-		log.Panic(err)
-		return
+		return nil, err
 	}
-}
-
-func ValidateToken(signedToken string) (claims *SignedDetails, err error) {
-	token, _ := jwt.ParseWithClaims(
-		signedToken,
-		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("SECRET_KEY")), nil
-		},
-	)
-
-	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
-		return nil, ErrInvalidToken
+	for _, revocation := range revocations {
+		if claims.IssuedAt < revocation.RevokedAt.Unix() {
+			return nil, ErrRevokedToken
+		}
 	}
 
-	if claims.ExpiresAt < time.Now().Unix() && claims.UserRole != "" {
-		return nil, ErrExpiredToken
+	refreshToken, err := tokens.UseRefreshToken(&ctx, claims.Id)
+	if err != nil {
+		// the session ends along with the access tokens issued from the family, which may be held by the thief
+		if err == ErrRefreshTokenReused {
+			revokeErr := RevokeSession(tokens, refreshToken.Family)
+			if revokeErr != nil {
+				return nil, revokeErr
+			}
+		}
+		return nil, err
 	}
 
-	if claims.UserRole == "" {
-		claims, err = renewTokens(claims.UserId)
-		if err != nil {
-			return nil, err
+	session, err := tokens.GetSession(&ctx, refreshToken.Family)
+	if err != nil {
+		if err == ErrSessionNotFound {
+			return nil, ErrInvalidToken
 		}
-		return claims, nil
+		return nil, err
 	}
 
-	return claims, nil
-}
+	user, err := users.GetUserById(&ctx, refreshToken.UserId)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
 
-func renewTokens(userId string) (*SignedDetails, error) {
-	ctx := context.Background()
-	user, err := GetUserById(&ctx, userId)
+	userTokens, err := IssueTokens(tokens, user, session.Id)
 	if err != nil {
-		return nil, ErrExpiredToken
+		return nil, err
 	}
 
-	refreshToken, err := jwt.ParseWithClaims(
-		*user.RefreshToken,
-		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("SECRET_KEY")), nil
-		},
-	)
+	now := time.Now()
+	err = tokens.TouchSession(&ctx, session.Id, now, now.Add(TokenTypeRefresh.Lifetime()))
 	if err != nil {
-		return nil, ErrExpiredToken
-	}
-
-	refreshClaims, ok := refreshToken.Claims.(*SignedDetails)
-	if !ok {
-		return nil, ErrExpiredToken
-	}
-
-	if refreshClaims.ExpiresAt >= time.Now().Unix() {
-		signedToken, signedRefreshToken, _ := GenerateAllTokens(user)
-		UpdateAllTokens(signedToken, signedRefreshToken, user.Id)
-		token, err := jwt.ParseWithClaims(
-			signedToken,
-			&SignedDetails{},
-			func(token *jwt.Token) (interface{}, error) {
-				return []byte(os.Getenv("SECRET_KEY")), nil
-			},
-		)
-		if err != nil {
-			return nil, ErrExpiredToken
-		}
-		return token.Claims.(*SignedDetails), nil
+		return nil, err
 	}
 
-	return nil, ErrExpiredToken
+	return userTokens, nil
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// defaultClockSkew is the tolerated difference between the clocks of the issuer and the verifiers
+const defaultClockSkew = 30 * time.Second
+
+// TokenIssuer reads the iss claim of issued tokens from JWT_ISSUER, SejutaCita by default
+func TokenIssuer() string {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		return "SejutaCita"
+	}
+	return issuer
+}
+
+// TokenAudience reads the aud claim of issued tokens from JWT_AUDIENCE, SejutaCita by default
+func TokenAudience() string {
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		return "SejutaCita"
+	}
+	return audience
+}
+
+// ClockSkew reads the tolerated clock skew from JWT_CLOCK_SKEW as a duration such as 30s, 30 seconds by default
+func ClockSkew() time.Duration {
+	skew, err := time.ParseDuration(os.Getenv("JWT_CLOCK_SKEW"))
+	if err != nil || skew < 0 {
+		return defaultClockSkew
+	}
+	return skew
+}
+
+// validate checks the registered claims of a token whose signature has been verified, tolerating the clock skew
+// on every time claim
+func (claims *SignedDetails) validate(now time.Time) error {
+	if claims.Id == "" || claims.Issuer == "" || claims.Audience == "" || claims.IssuedAt == 0 || claims.ExpiresAt == 0 {
+		return ErrTokenMissingClaim
+	}
+
+	if claims.Issuer != TokenIssuer() {
+		return ErrTokenIssuer
+	}
+	if claims.Audience != TokenAudience() {
+		return ErrTokenAudience
+	}
+
+	skew := int64(ClockSkew() / time.Second)
+	if now.Unix()+skew < claims.IssuedAt || now.Unix()+skew < claims.NotBefore {
+		return ErrTokenNotYetValid
+	}
+	if now.Unix()-skew >= claims.ExpiresAt {
+		return ErrExpiredToken
+	}
+
+	return nil
+}
+
+// IsTokenError returns whether the error is caused by the token presented by the client rather than by the server
+func IsTokenError(err error) bool {
+	return errors.Is(err, ErrInvalidToken) || err == ErrExpiredToken || err == ErrRevokedToken
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testClaims returns valid claims issued at now
+func testClaims(now time.Time) *SignedDetails {
+	return &SignedDetails{
+		UserId:    "user",
+		UserRoles: []UserRole{General},
+		Family:    "session",
+		StandardClaims: jwt.StandardClaims{
+			Id:        "token",
+			Issuer:    TokenIssuer(),
+			Audience:  TokenAudience(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(time.Hour).Unix(),
+		},
+	}
+}
+
+func TestClaimsValidation(t *testing.T) {
+	t.Setenv("JWT_CLOCK_SKEW", "30s")
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		change func(claims *SignedDetails)
+		want   error
+	}{
+		{"valid", func(claims *SignedDetails) {}, nil},
+		{"no jti", func(claims *SignedDetails) { claims.Id = "" }, ErrTokenMissingClaim},
+		{"no iss", func(claims *SignedDetails) { claims.Issuer = "" }, ErrTokenMissingClaim},
+		{"no aud", func(claims *SignedDetails) { claims.Audience = "" }, ErrTokenMissingClaim},
+		{"no iat", func(claims *SignedDetails) { claims.IssuedAt = 0 }, ErrTokenMissingClaim},
+		{"no exp", func(claims *SignedDetails) { claims.ExpiresAt = 0 }, ErrTokenMissingClaim},
+		{"other issuer", func(claims *SignedDetails) { claims.Issuer = "Other" }, ErrTokenIssuer},
+		{"other audience", func(claims *SignedDetails) { claims.Audience = "Other" }, ErrTokenAudience},
+		{"issued within the skew", func(claims *SignedDetails) { claims.IssuedAt = now.Add(20 * time.Second).Unix() }, nil},
+		{"issued beyond the skew", func(claims *SignedDetails) { claims.IssuedAt = now.Add(time.Minute).Unix() }, ErrTokenNotYetValid},
+		{"not before beyond the skew", func(claims *SignedDetails) { claims.NotBefore = now.Add(time.Minute).Unix() }, ErrTokenNotYetValid},
+		{"expired within the skew", func(claims *SignedDetails) { claims.ExpiresAt = now.Add(-20 * time.Second).Unix() }, nil},
+		{"expired beyond the skew", func(claims *SignedDetails) { claims.ExpiresAt = now.Add(-time.Minute).Unix() }, ErrExpiredToken},
+	}
+	for _, test := range tests {
+		claims := testClaims(now)
+		test.change(claims)
+		err := claims.validate(now)
+		if err != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+		if err != nil && !IsTokenError(err) {
+			t.Errorf("%s: %v is not a token error", test.name, err)
+		}
+	}
+
+	t.Setenv("JWT_CLOCK_SKEW", "0s")
+	claims := testClaims(now)
+	claims.ExpiresAt = now.Add(-20 * time.Second).Unix()
+	if err := claims.validate(now); err != ErrExpiredToken {
+		t.Errorf("expired without skew: got %v, want %v", err, ErrExpiredToken)
+	}
+}
+
+func TestParseTokenErrors(t *testing.T) {
+	keys := NewMemoryKeyStore()
+	err := EnsureSigningKey(keys, SigningAlgorithm())
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseKeyStore(keys)
+
+	signed, err := signToken(testClaims(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseToken(signed)
+	if err != nil || claims.UserId != "user" {
+		t.Fatalf("parsing a valid token: got %v, %v", claims, err)
+	}
+
+	key, err := keyring.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(time.Now()))
+	hmac.Header["kid"] = key.Id
+	hmacSigned, err := hmac.SignedString([]byte(key.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownKey := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(time.Now()))
+	unknownKey.Header["kid"] = "unknown"
+	unknownKeySigned, err := unknownKey.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(signed, ".")
+	other, err := signToken(&SignedDetails{UserId: "other", StandardClaims: testClaims(time.Now()).StandardClaims})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"malformed", "not a token", ErrTokenMalformed},
+		{"signed with an HMAC of the key", hmacSigned, ErrTokenAlgorithm},
+		{"unknown key", unknownKeySigned, ErrTokenUnknownKey},
+		{"tampered claims", tampered, ErrTokenSignature},
+	}
+	for _, test := range tests {
+		_, err := parseToken(test.token)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	t.Setenv("JWT_ISSUER", "Other")
+	_, err = parseToken(signed)
+	if err != ErrTokenIssuer {
+		t.Errorf("token of another issuer: got %v, want %v", err, ErrTokenIssuer)
+	}
+}
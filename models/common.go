@@ -11,6 +11,15 @@ type booleanResponseWrapper struct {
 	}
 }
 
+// The number of records affected by an operation
+// swagger:response countResponse
+type countResponseWrapper struct {
+	// in:body
+	Body struct {
+		Count int64
+	}
+}
+
 // swagger:enum SortOrder
 type SortOrder int
 
@@ -19,6 +28,14 @@ const (
 	Desc SortOrder = 1
 )
 
+// Direction returns the sort direction used by MongoDB, 1 for ascending and -1 for descending
+func (order SortOrder) Direction() int {
+	if order == Desc {
+		return -1
+	}
+	return 1
+}
+
 func EmptyValidate(fl validator.FieldLevel) bool {
 	return true
 }
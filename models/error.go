@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -32,18 +33,142 @@ var ErrInvalidToken = errors.New("invalid token")
 // ErrExpiredToken is an error raised when the token has expired
 var ErrExpiredToken = errors.New("expired token")
 
+// ErrTokenMalformed is an error raised when the token can not be decoded
+var ErrTokenMalformed = fmt.Errorf("%w: malformed", ErrInvalidToken)
+
+// ErrTokenAlgorithm is an error raised when the token is signed with another algorithm than the one of its key
+var ErrTokenAlgorithm = fmt.Errorf("%w: unexpected signing algorithm", ErrInvalidToken)
+
+// ErrTokenUnknownKey is an error raised when the key named in the kid header of the token is unknown
+var ErrTokenUnknownKey = fmt.Errorf("%w: unknown signing key", ErrInvalidToken)
+
+// ErrTokenSignature is an error raised when the signature of the token does not match its content
+var ErrTokenSignature = fmt.Errorf("%w: signature verification failed", ErrInvalidToken)
+
+// ErrTokenMissingClaim is an error raised when the token lacks one of the jti, iss, aud, iat or exp claims
+var ErrTokenMissingClaim = fmt.Errorf("%w: missing required claim", ErrInvalidToken)
+
+// ErrTokenIssuer is an error raised when the token was issued by another issuer
+var ErrTokenIssuer = fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+
+// ErrTokenAudience is an error raised when the token was issued for another audience
+var ErrTokenAudience = fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+
+// ErrTokenType is an error raised when a token of one type is used as another
+var ErrTokenType = fmt.Errorf("%w: unexpected token type", ErrInvalidToken)
+
+// ErrTokenNotYetValid is an error raised when the token is used before its iat or nbf time
+var ErrTokenNotYetValid = fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+
+// ErrTokenUnknownUser is an error raised when the user the token was issued to has been deleted
+var ErrTokenUnknownUser = fmt.Errorf("%w: the user of the token no longer exists", ErrInvalidToken)
+
+// ErrTokenUnknownAccessToken is an error raised when a personal access token is unknown, revoked or its user deleted
+var ErrTokenUnknownAccessToken = fmt.Errorf("%w: unknown personal access token", ErrInvalidToken)
+
+// ErrRevokedToken is an error raised when the token has been revoked by logging out
+var ErrRevokedToken = errors.New("revoked token")
+
+// ErrSigningKeyNotFound is an error raised when no key signs tokens or the key of a token is unknown
+var ErrSigningKeyNotFound = errors.New("signing key not found")
+
+// ErrInvalidSigningKey is an error raised when a stored signing key can not be decoded
+var ErrInvalidSigningKey = errors.New("invalid signing key")
+
+// ErrInvalidEncryptionKey is an error raised when the key encrypting the private signing keys is missing or malformed
+var ErrInvalidEncryptionKey = errors.New("SIGNING_KEY_ENCRYPTION_KEY must be a base64 encoded 32 byte key")
+
+// ErrInvalidAlgorithm is an error raised when a signing algorithm is not supported
+var ErrInvalidAlgorithm = errors.New("invalid algorithm, must be one of RS256, ES256 or EdDSA")
+
+// ErrLoginThrottled is an error raised when a login is attempted too soon after a failed one
+var ErrLoginThrottled = errors.New("too many failed logins, try again later")
+
+// ErrLoginLocked is an error raised when the username or the IP address is locked out after failed logins
+var ErrLoginLocked = errors.New("locked out after too many failed logins, try again later")
+
+// ErrInvalidPasswordHash is an error raised when a stored password hash can not be decoded
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// ErrSelfServiceField is an error raised when a user changes a field of their profile through self-service that
+// requires the users:write permission
+var ErrSelfServiceField = errors.New("field can not be changed through self-service")
+
+// ErrEmptyFirstName is an error raised when the first name of a user is cleared
+var ErrEmptyFirstName = errors.New("first name can not be empty")
+
+// ErrIncorrectPassword is an error raised when the current password sent to change the password is incorrect
+var ErrIncorrectPassword = errors.New("incorrect current password")
+
+// ErrPasswordPolicy is an error raised when a password violates the password policy
+var ErrPasswordPolicy = errors.New("password does not satisfy the password policy")
+
+// ErrAccessTokenNotFound is an error raised when the user has no personal access token with the ID
+var ErrAccessTokenNotFound = errors.New("personal access token not found")
+
+// ErrInvalidAccessToken is an error raised when a requested personal access token has an invalid name, scope or expiry
+var ErrInvalidAccessToken = errors.New("invalid personal access token")
+
+// ErrSessionRequired is an error raised when a personal access token is used on an operation that requires a session
+var ErrSessionRequired = errors.New("operation requires a session, personal access tokens can not be used")
+
+// ErrSessionNotFound is an error raised when the session does not exist or has ended
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrRefreshTokenReused is an error raised when a refresh token that was already exchanged is presented again
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected, the session has been revoked")
+
 // ErrUnauthorized is an error raised when the client is not authenticated
 var ErrUnauthorized = errors.New("not authenticated")
 
+// ErrRoleNotFound is an error raised when a role does not exist
+var ErrRoleNotFound = errors.New("role not found")
+
+// ErrDuplicateRole is an error raised when a role with a non-unique name is being created
+var ErrDuplicateRole = errors.New("role already exists")
+
+// ErrBuiltInRole is an error raised when a built-in role is deleted or the Admin role is changed
+var ErrBuiltInRole = errors.New("built-in role can not be changed")
+
+// ErrRoleInUse is an error raised when a role that users hold is deleted
+var ErrRoleInUse = errors.New("role is held by users")
+
+// ErrRoleEscalation is an error raised when a client grants a permission that it does not have
+var ErrRoleEscalation = errors.New("can not grant a permission the client does not have")
+
 // ErrForbidden is an error raised when a user accesses operations they are not authorized for
 var ErrForbidden = errors.New("insufficient access rights")
 
+// ErrInvalidPolicy is an error raised when the policy file can not be decoded or holds an invalid policy
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// ErrUndeclaredAccess is an error raised on startup when a route does not declare who is authorized to call it
+var ErrUndeclaredAccess = errors.New("route does not declare its access")
+
 // ErrUserNotFound is an error raised when a user can not be found in the database
 var ErrUserNotFound = errors.New("user not found")
 
+// ErrUserNotDeleted is an error raised when restoring a user that has not been deleted
+var ErrUserNotDeleted = errors.New("user is not deleted")
+
+// ErrUserUpdateConflict is an error raised when a user keeps being updated concurrently while it is being updated
+var ErrUserUpdateConflict = errors.New("user is being updated concurrently, try again")
+
 // ErrDuplicateUsername is an error raised when a user with a non-unique username is being created
 var ErrDuplicateUsername = errors.New("username already exists")
 
+// ErrSensitiveField is an error raised when a response would contain a password hash or token
+var ErrSensitiveField = errors.New("response contains a sensitive field")
+
+// ErrInvalidIP is an error raised when an IP address can not be parsed
+var ErrInvalidIP = errors.New("invalid IP address")
+
+// ErrInvalidCursor is an error raised when a pagination cursor is malformed or does not match the requested sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is an error raised when the sort of a list contains an unknown or repeated key
+var ErrInvalidSort = errors.New("invalid sort")
+
 // ErrJsonMarshal is an error raised when server fails to marshal to json
 var ErrJsonMarshal = errors.New("unable to marshal json")
 
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenType is the type of a token, telling what the token may be used for
+type TokenType string
+
+const (
+	TokenTypeAccess            TokenType = "access"
+	TokenTypeRefresh           TokenType = "refresh"
+	TokenTypePasswordReset     TokenType = "password_reset"
+	TokenTypeEmailVerification TokenType = "email_verification"
+	TokenTypeInvitation        TokenType = "invitation"
+)
+
+// tokenTypes are the token types with the variable overriding their lifetime and their default lifetime
+var tokenTypes = map[TokenType]struct {
+	env      string
+	lifetime time.Duration
+}{
+	TokenTypeAccess:            {"ACCESS_TOKEN_LIFETIME", 24 * time.Hour},
+	TokenTypeRefresh:           {"REFRESH_TOKEN_LIFETIME", 168 * time.Hour},
+	TokenTypePasswordReset:     {"PASSWORD_RESET_TOKEN_LIFETIME", time.Hour},
+	TokenTypeEmailVerification: {"EMAIL_VERIFICATION_TOKEN_LIFETIME", 24 * time.Hour},
+	TokenTypeInvitation:        {"INVITATION_TOKEN_LIFETIME", 168 * time.Hour},
+}
+
+// Lifetime reads how long tokens of the type are valid from its variable as a duration such as 15m,
+// falling back to the default lifetime of the type
+func (tokenType TokenType) Lifetime() time.Duration {
+	config := tokenTypes[tokenType]
+	lifetime, err := time.ParseDuration(os.Getenv(config.env))
+	if err != nil || lifetime <= 0 {
+		return config.lifetime
+	}
+	return lifetime
+}
+
+// maxTokenLifetime returns the lifetime of the longest lived token type, a retired key must keep verifying
+// tokens for that long
+func maxTokenLifetime() time.Duration {
+	max := time.Duration(0)
+	for tokenType := range tokenTypes {
+		if lifetime := tokenType.Lifetime(); lifetime > max {
+			max = lifetime
+		}
+	}
+	return max
+}
+
+// IssueToken fills the registered claims and the type of the claims and signs them, every token is signed through it
+func IssueToken(tokenType TokenType, claims *SignedDetails) (string, *SignedDetails, error) {
+	if _, ok := tokenTypes[tokenType]; !ok {
+		return "", nil, ErrTokenType
+	}
+
+	now := time.Now()
+	claims.Type = tokenType
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        primitive.NewObjectID().Hex(),
+		Issuer:    TokenIssuer(),
+		Audience:  TokenAudience(),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(tokenType.Lifetime()).Unix(),
+	}
+
+	signedToken, err := signToken(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signedToken, claims, nil
+}
+
+// ParseToken verifies the token and returns its claims when it is of the type
+func ParseToken(tokenType TokenType, signedToken string) (*SignedDetails, error) {
+	claims, err := parseToken(signedToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != tokenType {
+		return nil, ErrTokenType
+	}
+	return claims, nil
+}
+
+// signToken signs the claims with the active key, naming it in the kid header
+func signToken(claims jwt.Claims) (string, error) {
+	key, err := keyring.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	method, err := key.method()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.Id
+	return token.SignedString(key.signer)
+}
+
+// parseToken verifies the signature and the registered claims of a signed token and returns its claims. The token
+// must be signed with the algorithm of the key named in its kid header so that a public key can never be used as an
+// HMAC secret, and its claims are only checked once the signature is verified.
+func parseToken(signedToken string) (*SignedDetails, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(
+		signedToken,
+		&SignedDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			kid, ok := token.Header["kid"].(string)
+			if !ok {
+				return nil, ErrTokenUnknownKey
+			}
+
+			key, err := keyring.verificationKey(kid)
+			if err != nil {
+				if err == ErrSigningKeyNotFound {
+					return nil, ErrTokenUnknownKey
+				}
+				return nil, err
+			}
+			if token.Method.Alg() != key.Algorithm {
+				return nil, ErrTokenAlgorithm
+			}
+			return key.signer.Public(), nil
+		},
+	)
+	if err != nil {
+		validationErr, ok := err.(*jwt.ValidationError)
+		switch {
+		case !ok || validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+			return nil, ErrTokenMalformed
+		case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+			// without an inner error the algorithm of the token is not even registered
+			if validationErr.Inner == nil {
+				return nil, ErrTokenAlgorithm
+			}
+			return nil, validationErr.Inner
+		case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+			return nil, ErrTokenSignature
+		}
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*SignedDetails)
+	if !ok {
+		return nil, ErrTokenMalformed
+	}
+
+	err = claims.validate(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,324 @@
+package models
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The public keys that verify the tokens
+// swagger:response jwksResponse
+type jwksResponseWrapper struct {
+	// in:body
+	Body JSONWebKeySet
+}
+
+// SigningKey is a key pair signing and verifying tokens, identified in the kid header of the tokens it signs.
+// A key is pending once generated and only published, active once promoted and used to sign, and retired once
+// another key is promoted, still verifying the tokens it signed until they expire.
+type SigningKey struct {
+	// the key ID
+	Id string `bson:"_id"`
+	// the JWS algorithm of the key, RS256, ES256 or EdDSA
+	Algorithm string `bson:"algorithm"`
+	// the private key as a PKCS #8 PEM block, sealed with sealPrivateKey by the stores that persist it
+	PrivateKey string `bson:"private_key"`
+	// the date the key was generated at
+	CreatedAt time.Time `bson:"created_at"`
+	// the date the key was promoted at
+	ActivatedAt *time.Time `bson:"activated_at"`
+	// the date another key was promoted at
+	RetiredAt *time.Time `bson:"retired_at"`
+	// the date the tokens signed by the retired key have all expired at
+	ExpiresAt *time.Time `bson:"expires_at"`
+
+	signer crypto.Signer
+}
+
+// KeyStore defines the persistence operations on signing keys
+type KeyStore interface {
+	CreateSigningKey(ctx *context.Context, key SigningKey) error
+	// GetSigningKeys returns the keys that have not expired, the most recently generated first
+	GetSigningKeys(ctx *context.Context) ([]*SigningKey, error)
+	// PromoteSigningKey activates the key and retires the previously active keys, returning ErrSigningKeyNotFound
+	// when the key does not exist or was already retired
+	PromoteSigningKey(ctx *context.Context, id string, activatedAt time.Time, retiredExpiresAt time.Time) error
+}
+
+// SigningAlgorithm reads the algorithm of generated keys from JWT_ALGORITHM, RS256 by default
+func SigningAlgorithm() string {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	if algorithm == "" {
+		return jwt.SigningMethodRS256.Alg()
+	}
+	return algorithm
+}
+
+// sealedPrivateKeyPrefix marks the private keys encrypted by sealPrivateKey
+const sealedPrivateKeyPrefix = "aes256gcm:"
+
+// SigningKeyEncryptionKey reads from SIGNING_KEY_ENCRYPTION_KEY the base64 encoded 32 byte key that encrypts the
+// private signing keys at rest, returning ErrInvalidEncryptionKey when it is missing or malformed
+func SigningKeyEncryptionKey() ([]byte, error) {
+	kek, err := base64.StdEncoding.DecodeString(os.Getenv("SIGNING_KEY_ENCRYPTION_KEY"))
+	if err != nil || len(kek) != 32 {
+		return nil, ErrInvalidEncryptionKey
+	}
+	return kek, nil
+}
+
+// signingKeyCipher returns the AES-256-GCM cipher of the key encryption key
+func signingKeyCipher() (cipher.AEAD, error) {
+	kek, err := SigningKeyEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPrivateKey encrypts the private key with AES-256-GCM under the key encryption key, bound to the key ID so that
+// a sealed private key can not be moved to another key
+func (key *SigningKey) sealPrivateKey() (string, error) {
+	aead, err := signingKeyCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(key.PrivateKey), []byte(key.Id))
+	return sealedPrivateKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openPrivateKey decrypts the private key sealed by sealPrivateKey, returning ErrInvalidSigningKey when it is not
+// sealed or was not sealed for this key under the key encryption key
+func (key *SigningKey) openPrivateKey(sealed string) (string, error) {
+	aead, err := signingKeyCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(sealed, sealedPrivateKeyPrefix) {
+		return "", ErrInvalidSigningKey
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrivateKeyPrefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrInvalidSigningKey
+	}
+	privateKey, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(key.Id))
+	if err != nil {
+		return "", ErrInvalidSigningKey
+	}
+	return string(privateKey), nil
+}
+
+// GenerateSigningKey generates a pending key pair for the algorithm
+func GenerateSigningKey(store KeyStore, algorithm string) (*SigningKey, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case SigningMethodEdDSA.Alg():
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	key := SigningKey{
+		Id:         primitive.NewObjectID().Hex(),
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  time.Now(),
+		signer:     signer,
+	}
+
+	ctx := context.Background()
+	err = store.CreateSigningKey(&ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// PromoteSigningKey makes the key the one signing new tokens, the previously active key keeps verifying the
+// tokens it signed until the longest lived of them has expired
+func PromoteSigningKey(store KeyStore, id string) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	err := store.PromoteSigningKey(&ctx, id, now, now.Add(maxTokenLifetime()))
+	if err != nil {
+		return err
+	}
+
+	if keyring != nil {
+		keyring.invalidate()
+	}
+	return nil
+}
+
+// EnsureSigningKey generates and promotes a key when the store has no active key
+func EnsureSigningKey(store KeyStore, algorithm string) error {
+	ctx := context.Background()
+
+	keys, err := store.GetSigningKeys(&ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.Active() {
+			return nil
+		}
+	}
+
+	key, err := GenerateSigningKey(store, algorithm)
+	if err != nil {
+		return err
+	}
+	return PromoteSigningKey(store, key.Id)
+}
+
+// Status returns whether the key is pending, active or retired
+func (key *SigningKey) Status() string {
+	switch {
+	case key.ActivatedAt == nil:
+		return "pending"
+	case key.RetiredAt == nil:
+		return "active"
+	default:
+		return "retired"
+	}
+}
+
+func (key *SigningKey) Active() bool {
+	return key.Status() == "active"
+}
+
+// method returns the JWS signing method of the key
+func (key *SigningKey) method() (jwt.SigningMethod, error) {
+	switch key.Algorithm {
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg(), SigningMethodEdDSA.Alg():
+		return jwt.GetSigningMethod(key.Algorithm), nil
+	}
+	return nil, ErrInvalidAlgorithm
+}
+
+// parse decodes the PEM encoded private key of a key loaded from the store
+func (key *SigningKey) parse() error {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return ErrInvalidSigningKey
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return ErrInvalidSigningKey
+	}
+	key.signer = signer
+	return nil
+}
+
+// JSONWebKey is the public part of a signing key as published in the JWKS
+// swagger:model
+type JSONWebKey struct {
+	// the key type, RSA, EC or OKP
+	// required:true
+	Kty string `json:"kty"`
+	// the key ID, matched against the kid header of tokens
+	// required:true
+	Kid string `json:"kid"`
+	// the use of the key, always sig
+	// required:true
+	Use string `json:"use"`
+	// the JWS algorithm of the key
+	// required:true
+	Alg string `json:"alg"`
+	// the RSA modulus
+	N string `json:"n,omitempty"`
+	// the RSA public exponent
+	E string `json:"e,omitempty"`
+	// the curve of EC and OKP keys
+	Crv string `json:"crv,omitempty"`
+	// the x coordinate of EC keys or the public key of OKP keys
+	X string `json:"x,omitempty"`
+	// the y coordinate of EC keys
+	Y string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the set of public keys verifying the tokens
+// swagger:model
+type JSONWebKeySet struct {
+	// required:true
+	Keys []JSONWebKey `json:"keys"`
+}
+
+func (set *JSONWebKeySet) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, set)
+}
+
+// publicJWK returns the public part of the key in the JWK format
+func (key *SigningKey) publicJWK() JSONWebKey {
+	jwk := JSONWebKey{Kid: key.Id, Use: "sig", Alg: key.Algorithm}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch publicKey := key.signer.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encode(publicKey.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// the coordinates are padded to the size of the curve
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = encode(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encode(publicKey)
+	}
+
+	return jwk
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryKeyStore is a KeyStore that keeps signing keys in memory, used for tests and local demos
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]SigningKey
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys: map[string]SigningKey{},
+	}
+}
+
+func (s *MemoryKeyStore) CreateSigningKey(ctx *context.Context, key SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.Id] = key
+	return nil
+}
+
+func (s *MemoryKeyStore) GetSigningKeys(ctx *context.Context) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := []*SigningKey{}
+	for id, key := range s.keys {
+		if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+			delete(s.keys, id)
+			continue
+		}
+		key := key
+		keys = append(keys, &key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *MemoryKeyStore) PromoteSigningKey(ctx *context.Context, id string, activatedAt time.Time, retiredExpiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promoted, ok := s.keys[id]
+	if !ok || promoted.RetiredAt != nil {
+		return ErrSigningKeyNotFound
+	}
+	promoted.ActivatedAt = &activatedAt
+	s.keys[id] = promoted
+
+	for keyId, key := range s.keys {
+		if keyId != id && key.Active() {
+			key.RetiredAt = &activatedAt
+			key.ExpiresAt = &retiredExpiresAt
+			s.keys[keyId] = key
+		}
+	}
+	return nil
+}
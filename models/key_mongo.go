@@ -0,0 +1,84 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoKeyStore is a KeyStore backed by the signing_keys collection in MongoDB, the private keys are stored sealed
+// under SIGNING_KEY_ENCRYPTION_KEY so that reading the database is not enough to sign tokens
+type MongoKeyStore struct{}
+
+func NewMongoKeyStore() *MongoKeyStore {
+	return &MongoKeyStore{}
+}
+
+func (s *MongoKeyStore) CreateSigningKey(ctx *context.Context, key SigningKey) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	key.PrivateKey, err = key.sealPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("signing_keys").InsertOne(*ctx, key)
+	return err
+}
+
+func (s *MongoKeyStore) GetSigningKeys(ctx *context.Context) ([]*SigningKey, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the TTL monitor only runs periodically so expired keys are filtered out explicitly
+	filter := bson.M{"$or": bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": time.Now()}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cur, err := db.Collection("signing_keys").Find(*ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*SigningKey{}
+	err = cur.All(*ctx, &keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		key.PrivateKey, err = key.openPrivateKey(key.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *MongoKeyStore) PromoteSigningKey(ctx *context.Context, id string, activatedAt time.Time, retiredExpiresAt time.Time) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": id, "retired_at": nil}
+	updater := bson.M{"$set": bson.M{"activated_at": activatedAt}}
+	result, err := db.Collection("signing_keys").UpdateOne(*ctx, filter, updater)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSigningKeyNotFound
+	}
+
+	filter = bson.M{"_id": bson.M{"$ne": id}, "activated_at": bson.M{"$ne": nil}, "retired_at": nil}
+	updater = bson.M{"$set": bson.M{"retired_at": activatedAt, "expires_at": retiredExpiresAt}}
+	_, err = db.Collection("signing_keys").UpdateMany(*ctx, filter, updater)
+	return err
+}
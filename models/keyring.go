@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// keyringRefreshInterval is how long the keys are cached before being reloaded, so that a key promoted by the
+// keys command is picked up by every running instance
+const keyringRefreshInterval = time.Minute
+
+// keyringMissRefreshInterval is how soon the keys may be reloaded when a token names an unknown key
+const keyringMissRefreshInterval = 5 * time.Second
+
+// Keyring caches the signing keys of a KeyStore
+type Keyring struct {
+	store    KeyStore
+	mu       sync.Mutex
+	keys     []*SigningKey
+	loadedAt time.Time
+}
+
+// keyring signs and verifies every token, set once on startup by UseKeyStore
+var keyring *Keyring
+
+// UseKeyStore makes the keys of the store sign and verify tokens
+func UseKeyStore(store KeyStore) {
+	keyring = &Keyring{store: store}
+}
+
+// invalidate makes the next use of the keyring reload the keys
+func (k *Keyring) invalidate() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.loadedAt = time.Time{}
+}
+
+// load returns the cached keys, reloading them from the store when they are older than maxAge
+func (k *Keyring) load(maxAge time.Duration) ([]*SigningKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys != nil && time.Since(k.loadedAt) < maxAge {
+		return k.keys, nil
+	}
+
+	ctx := context.Background()
+	keys, err := k.store.GetSigningKeys(&ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.signer != nil {
+			continue
+		}
+		err = key.parse()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	k.keys = keys
+	k.loadedAt = time.Now()
+	return keys, nil
+}
+
+// signingKey returns the active key
+func (k *Keyring) signingKey() (*SigningKey, error) {
+	keys, err := k.load(keyringRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.Active() {
+			return key, nil
+		}
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+// verificationKey returns the key with the ID, reloading the keys once when it is unknown since another
+// instance may have promoted a key that was generated after the last load
+func (k *Keyring) verificationKey(id string) (*SigningKey, error) {
+	for _, maxAge := range []time.Duration{keyringRefreshInterval, keyringMissRefreshInterval} {
+		keys, err := k.load(maxAge)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if key.Id == id && key.Status() != "pending" {
+				return key, nil
+			}
+		}
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+// JWKS returns the public keys verifying tokens, pending keys are published ahead of their promotion so that
+// verifiers already know them when they start signing
+func (k *Keyring) JWKS() (*JSONWebKeySet, error) {
+	keys, err := k.load(keyringRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.publicJWK())
+	}
+	return set, nil
+}
+
+// GetJWKS returns the public keys of the keyring in use
+func GetJWKS() (*JSONWebKeySet, error) {
+	return keyring.JWKS()
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// swagger:parameters unlockUser
+type unlockUserParameterWrapper struct {
+	// The ID of the user to unlock
+	// in:query
+	// required:true
+	Id string `json:"id"`
+	// The IP address whose failed logins are forgotten as well, e.g. the address the user logs in from
+	// in:query
+	IP string `json:"ip"`
+}
+
+// LoginAttempts counts the failed logins of a username or of an IP address within the attempt window
+type LoginAttempts struct {
+	// "username:" followed by the username, or "ip:" followed by the IP address
+	Id string `bson:"_id"`
+	// the number of failed logins since the counter was reset
+	Failures int `bson:"failures"`
+	// the date of the last failed login
+	LastFailureAt time.Time `bson:"last_failure_at"`
+	// the date the logins are allowed again after a lockout
+	LockedUntil *time.Time `bson:"locked_until"`
+	// the date the counter is reset at
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// LoginAttemptStore defines the persistence operations on the failed login counters, shared by every instance
+type LoginAttemptStore interface {
+	// GetLoginAttempts returns the counters among the given IDs that have not expired
+	GetLoginAttempts(ctx *context.Context, ids []string) ([]LoginAttempts, error)
+	// RecordLoginFailure increments the counter, starting it over when it has expired, and returns it
+	RecordLoginFailure(ctx *context.Context, id string, at time.Time, expiresAt time.Time) (*LoginAttempts, error)
+	// LockLogin refuses the logins of the counter until the date, at which the counter is reset
+	LockLogin(ctx *context.Context, id string, until time.Time) error
+	ResetLoginAttempts(ctx *context.Context, id string) error
+}
+
+const (
+	// loginBaseDelay is the delay after the first failed login, doubled on every further failure
+	loginBaseDelay = time.Second
+	// loginMaxDelay caps the delay between failed logins
+	loginMaxDelay = 30 * time.Second
+)
+
+// LoginThrottle is the configuration of the lockout of usernames and IP addresses after failed logins
+type LoginThrottle struct {
+	// the failed logins of a username within the window that lock it out
+	UsernameThreshold int
+	// the failed logins from an IP address within the window that lock it out
+	IPThreshold int
+	// how long a username or an IP address is locked out
+	LockoutDuration time.Duration
+	// how long failed logins are counted
+	Window time.Duration
+}
+
+// LoginThrottleConfig reads the configuration of the lockout from LOGIN_LOCKOUT_THRESHOLD (5 by default),
+// LOGIN_IP_LOCKOUT_THRESHOLD (20), LOGIN_LOCKOUT_DURATION (15m) and LOGIN_ATTEMPT_WINDOW (15m)
+func LoginThrottleConfig() LoginThrottle {
+	config := LoginThrottle{
+		UsernameThreshold: 5,
+		IPThreshold:       20,
+		LockoutDuration:   15 * time.Minute,
+		Window:            15 * time.Minute,
+	}
+
+	if threshold, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_THRESHOLD")); err == nil && threshold > 0 {
+		config.UsernameThreshold = threshold
+	}
+	if threshold, err := strconv.Atoi(os.Getenv("LOGIN_IP_LOCKOUT_THRESHOLD")); err == nil && threshold > 0 {
+		config.IPThreshold = threshold
+	}
+	if duration, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION")); err == nil && duration > 0 {
+		config.LockoutDuration = duration
+	}
+	if window, err := time.ParseDuration(os.Getenv("LOGIN_ATTEMPT_WINDOW")); err == nil && window > 0 {
+		config.Window = window
+	}
+
+	return config
+}
+
+// TrustedProxies are the networks of the proxies in front of the API, whose X-Forwarded-For header is believed
+type TrustedProxies []*net.IPNet
+
+// TrustedProxiesConfig reads the trusted proxies from TRUSTED_PROXIES, a comma separated list of IP addresses and
+// CIDR ranges, none by default so that the header can not be forged by clients connecting directly
+func TrustedProxiesConfig() TrustedProxies {
+	proxies := TrustedProxies{}
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+		}
+	}
+	return proxies
+}
+
+// Contains returns whether the IP address belongs to one of the trusted proxies
+func (proxies TrustedProxies) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client of a request received from the remote address. Behind trusted
+// proxies it is the rightmost address of the X-Forwarded-For header that is not a trusted proxy, the addresses left
+// of it were sent by the client and can be forged.
+func (proxies TrustedProxies) ClientIP(remoteAddr string, forwardedFor []string) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+
+	forwarded := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && proxies.Contains(ip); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+	}
+	return ip
+}
+
+func usernameAttemptsId(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func ipAttemptsId(ip string) string {
+	// an address has a single counter however it is written
+	if parsed := net.ParseIP(ip); parsed != nil {
+		ip = parsed.String()
+	}
+	return "ip:" + ip
+}
+
+// loginDelay returns how long to wait after the number of consecutive failed logins
+func loginDelay(failures int) time.Duration {
+	delay := loginBaseDelay
+	for i := 1; i < failures && delay < loginMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > loginMaxDelay {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+// CheckLoginThrottle returns ErrLoginLocked while the username or the IP address is locked out and ErrLoginThrottled
+// until the delay after the last failed login of the username has passed, along with how long the client has to wait
+func CheckLoginThrottle(attempts LoginAttemptStore, username string, ip string) (time.Duration, error) {
+	ctx := context.Background()
+	now := time.Now()
+
+	counters, err := attempts.GetLoginAttempts(&ctx, []string{usernameAttemptsId(username), ipAttemptsId(ip)})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, counter := range counters {
+		if counter.LockedUntil != nil && counter.LockedUntil.After(now) {
+			return counter.LockedUntil.Sub(now), ErrLoginLocked
+		}
+	}
+
+	// only the failed logins of the username delay the next ones, so that the users sharing an IP address are not
+	// slowed down by one of them
+	for _, counter := range counters {
+		if counter.Id != usernameAttemptsId(username) {
+			continue
+		}
+		if wait := counter.LastFailureAt.Add(loginDelay(counter.Failures)).Sub(now); wait > 0 {
+			return wait, ErrLoginThrottled
+		}
+	}
+
+	return 0, nil
+}
+
+// RecordLoginFailure counts a failed login of the username from the IP address, locking either out once it
+// reaches its threshold
+func RecordLoginFailure(attempts LoginAttemptStore, username string, ip string) error {
+	ctx := context.Background()
+	now := time.Now()
+	config := LoginThrottleConfig()
+
+	thresholds := map[string]int{
+		usernameAttemptsId(username): config.UsernameThreshold,
+		ipAttemptsId(ip):             config.IPThreshold,
+	}
+	for id, threshold := range thresholds {
+		counter, err := attempts.RecordLoginFailure(&ctx, id, now, now.Add(config.Window))
+		if err != nil {
+			return err
+		}
+
+		if counter.Failures >= threshold {
+			err = attempts.LockLogin(&ctx, id, now.Add(config.LockoutDuration))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins of the username, the counter of the IP address is kept so that a
+// client can not reset it by logging into an account of its own
+func ResetLoginFailures(attempts LoginAttemptStore, username string) error {
+	ctx := context.Background()
+	return attempts.ResetLoginAttempts(&ctx, usernameAttemptsId(username))
+}
+
+// ResetIPLoginFailures forgets the failed logins from the IP address, which unlocks every username for it
+func ResetIPLoginFailures(attempts LoginAttemptStore, ip string) error {
+	ctx := context.Background()
+	return attempts.ResetLoginAttempts(&ctx, ipAttemptsId(ip))
+}
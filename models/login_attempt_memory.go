@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptStore is a LoginAttemptStore that keeps the counters in memory, used for tests and local demos
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	counters map[string]LoginAttempts
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		counters: map[string]LoginAttempts{},
+	}
+}
+
+func (s *MemoryLoginAttemptStore) GetLoginAttempts(ctx *context.Context, ids []string) ([]LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	counters := []LoginAttempts{}
+	for _, id := range ids {
+		counter, ok := s.counters[id]
+		if !ok {
+			continue
+		}
+		if !counter.ExpiresAt.After(now) {
+			delete(s.counters, id)
+			continue
+		}
+		counters = append(counters, counter)
+	}
+	return counters, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordLoginFailure(ctx *context.Context, id string, at time.Time, expiresAt time.Time) (*LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[id]
+	if !ok || !counter.ExpiresAt.After(at) {
+		counter = LoginAttempts{Id: id}
+	}
+	counter.Failures++
+	counter.LastFailureAt = at
+	if expiresAt.After(counter.ExpiresAt) {
+		counter.ExpiresAt = expiresAt
+	}
+	s.counters[id] = counter
+
+	return &counter, nil
+}
+
+func (s *MemoryLoginAttemptStore) LockLogin(ctx *context.Context, id string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[id]
+	if !ok {
+		return nil
+	}
+	counter.LockedUntil = &until
+	counter.ExpiresAt = until
+	s.counters[id] = counter
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) ResetLoginAttempts(ctx *context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, id)
+	return nil
+}
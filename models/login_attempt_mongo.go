@@ -0,0 +1,88 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoLoginAttemptStore is a LoginAttemptStore backed by the login_attempts collection in MongoDB
+type MongoLoginAttemptStore struct{}
+
+func NewMongoLoginAttemptStore() *MongoLoginAttemptStore {
+	return &MongoLoginAttemptStore{}
+}
+
+func (s *MongoLoginAttemptStore) GetLoginAttempts(ctx *context.Context, ids []string) ([]LoginAttempts, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the TTL monitor only runs periodically so expired counters are filtered out explicitly
+	filter := bson.M{"_id": bson.M{"$in": ids}, "expires_at": bson.M{"$gt": time.Now()}}
+	cur, err := db.Collection("login_attempts").Find(*ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := []LoginAttempts{}
+	err = cur.All(*ctx, &counters)
+	if err != nil {
+		return nil, err
+	}
+
+	return counters, nil
+}
+
+func (s *MongoLoginAttemptStore) RecordLoginFailure(ctx *context.Context, id string, at time.Time, expiresAt time.Time) (*LoginAttempts, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the counter is incremented in a single update so that the failures seen by every instance add up,
+	// an expired counter that the TTL monitor has not removed yet starts over
+	current := bson.M{"$gt": bson.A{"$expires_at", at}}
+	updater := bson.A{bson.M{"$set": bson.M{
+		"failures":        bson.M{"$cond": bson.A{current, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+		"locked_until":    bson.M{"$cond": bson.A{current, "$locked_until", nil}},
+		"last_failure_at": at,
+		"expires_at":      bson.M{"$cond": bson.A{current, bson.M{"$max": bson.A{"$expires_at", expiresAt}}, expiresAt}},
+	}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	counter := LoginAttempts{}
+	err = db.Collection("login_attempts").FindOneAndUpdate(*ctx, bson.M{"_id": id}, updater, opts).Decode(&counter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &counter, nil
+}
+
+func (s *MongoLoginAttemptStore) LockLogin(ctx *context.Context, id string, until time.Time) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": id}
+	updater := bson.M{"$set": bson.M{"locked_until": until, "expires_at": until}}
+
+	_, err = db.Collection("login_attempts").UpdateOne(*ctx, filter, updater)
+	return err
+}
+
+func (s *MongoLoginAttemptStore) ResetLoginAttempts(ctx *context.Context, id string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("login_attempts").DeleteOne(*ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-playground/validator"
+	"golang.org/x/text/language"
+)
+
+// The user that is returned to the user themselves
+// swagger:response userSelfResponse
+type userSelfResponseWrapper struct {
+	// in:body
+	Body UserSelfView
+}
+
+// swagger:parameters changePassword
+type passwordChangeParameterWrapper struct {
+	// The current and the new password of the client
+	// in:body
+	// required:true
+	Body PasswordChange
+}
+
+// swagger:parameters updateMe
+type userProfileUpdateParameterWrapper struct {
+	// The fields of the profile of the client that are changed, the others are left untouched
+	// in:body
+	// required:true
+	Body UserProfileUpdate
+}
+
+// PasswordChange defines the passwords sent by a user changing their password
+// swagger:model
+type PasswordChange struct {
+	// the current password of the user
+	// required:true
+	CurrentPassword string `json:"current_password"`
+	// the new password of the user, checked against the password policy and the previous passwords
+	// required:true
+	NewPassword string `json:"new_password"`
+}
+
+func (change *PasswordChange) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(change)
+}
+
+// UserPreferences defines the preferences of a User
+// swagger:model
+type UserPreferences struct {
+	// the preferred language of the user as a BCP 47 tag, e.g. id or en-US
+	Language string `bson:"language" json:"language"`
+	// the time zone of the user as an IANA name, e.g. Asia/Jakarta
+	Timezone string `bson:"timezone" json:"timezone"`
+}
+
+// UserPreferencesUpdate defines the preferences changed by a user, omitted preferences are left untouched
+// swagger:model
+type UserPreferencesUpdate struct {
+	// the preferred language of the user as a BCP 47 tag, an empty string clears it
+	Language *string `json:"language" validate:"omitempty,len=0|language"`
+	// the time zone of the user as an IANA name, an empty string clears it
+	Timezone *string `json:"timezone" validate:"omitempty,len=0|timezone"`
+}
+
+// UserProfileUpdate defines the fields a user can change on their own profile through PATCH /me, omitted fields
+// are left untouched and an empty string clears an optional field. The roles, the attributes, the username and the
+// password can not be changed through it.
+// swagger:model
+type UserProfileUpdate struct {
+	// the first name of the user, it can not be cleared
+	FirstName *string `json:"first_name"`
+	// the middle name of the user
+	MiddleName *string `json:"middle_name"`
+	// the last name of the user
+	LastName *string `json:"last_name"`
+	// the email address of the user
+	Email *string `json:"email" validate:"omitempty,len=0|email"`
+	// the phone number of the user in the E.164 format, e.g. +6281234567890
+	Phone *string `json:"phone" validate:"omitempty,len=0|e164"`
+	// the preferences of the user
+	Preferences *UserPreferencesUpdate `json:"preferences"`
+}
+
+// selfServiceForbiddenFields are the fields of a user that only the users:write permission allows changing
+var selfServiceForbiddenFields = []string{"roles", "attributes", "username", "password"}
+
+// FromJSON decodes the profile update, returning ErrSelfServiceField when it changes a field that self-service can not
+// change and ErrJsonUnmarshal when it holds any other unknown field
+func (profile *UserProfileUpdate) FromJSON(r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(body, &fields)
+	if err != nil {
+		return ErrJsonUnmarshal
+	}
+	for _, field := range selfServiceForbiddenFields {
+		if _, ok := fields[field]; ok {
+			return fmt.Errorf("%w: %s", ErrSelfServiceField, field)
+		}
+	}
+
+	e := json.NewDecoder(bytes.NewReader(body))
+	e.DisallowUnknownFields()
+	err = e.Decode(profile)
+	if err != nil {
+		return ErrJsonUnmarshal
+	}
+	return nil
+}
+
+func (profile *UserProfileUpdate) Validate() error {
+	validate := validator.New()
+	validate.RegisterValidation("language", validateLanguage)
+	validate.RegisterValidation("timezone", validateTimezone)
+
+	if profile.FirstName != nil && *profile.FirstName == "" {
+		return ErrEmptyFirstName
+	}
+	return validate.Struct(profile)
+}
+
+func validateLanguage(fl validator.FieldLevel) bool {
+	_, err := language.Parse(fl.Field().String())
+	return err == nil
+}
+
+func validateTimezone(fl validator.FieldLevel) bool {
+	_, err := time.LoadLocation(fl.Field().String())
+	return err == nil && fl.Field().String() != "Local"
+}
+
+// applyTo changes the fields of the user given in the profile update
+func (profile *UserProfileUpdate) applyTo(user *User) {
+	// optionalString returns nil for an empty string so that the field is cleared
+	optionalString := func(value *string) *string {
+		if *value == "" {
+			return nil
+		}
+		return value
+	}
+
+	if profile.FirstName != nil {
+		user.FirstName = *profile.FirstName
+	}
+	if profile.MiddleName != nil {
+		user.MiddleName = optionalString(profile.MiddleName)
+	}
+	if profile.LastName != nil {
+		user.LastName = optionalString(profile.LastName)
+	}
+	if profile.Email != nil {
+		user.Email = optionalString(profile.Email)
+	}
+	if profile.Phone != nil {
+		user.Phone = optionalString(profile.Phone)
+	}
+	if profile.Preferences != nil {
+		if profile.Preferences.Language != nil {
+			user.Preferences.Language = *profile.Preferences.Language
+		}
+		if profile.Preferences.Timezone != nil {
+			user.Preferences.Timezone = *profile.Preferences.Timezone
+		}
+	}
+	user.SearchTerms = UserSearchTerms(user)
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords into self describing encoded hashes, so that a hash keeps verifying after the
+// algorithm or its parameters are changed
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password with a random salt
+	Hash(password string) (string, error)
+	// Handles returns whether the encoded hash was produced by the algorithm of the hasher
+	Handles(encoded string) bool
+	// Verify returns whether the password matches the encoded hash
+	Verify(password string, encoded string) (bool, error)
+	// Outdated returns whether the encoded hash was produced with other parameters than those of the hasher
+	Outdated(encoded string) bool
+}
+
+// Argon2idHasher hashes passwords with argon2id into PHC strings such as $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+type Argon2idHasher struct {
+	// the memory used in KiB
+	Memory uint32
+	// the number of passes over the memory
+	Iterations uint32
+	// the number of threads
+	Parallelism uint8
+}
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2idKeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *Argon2idHasher) Verify(password string, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (h *Argon2idHasher) Outdated(encoded string) bool {
+	params, _, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return *params != *h || len(key) != argon2idKeyLength
+}
+
+// decodeArgon2id returns the parameters, the salt and the key of an argon2id PHC string
+func decodeArgon2id(encoded string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	params := &Argon2idHasher{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	return params, salt, key, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt in its modular crypt format such as $2a$12$saltandhash
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *BcryptHasher) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *BcryptHasher) Outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// PasswordHasherConfig reads the hasher of new passwords from PASSWORD_HASHER, argon2id by default or bcrypt.
+// The argon2id parameters are read from ARGON2_MEMORY in KiB (65536 by default), ARGON2_ITERATIONS (3) and
+// ARGON2_PARALLELISM (2), the bcrypt cost from BCRYPT_COST (12).
+func PasswordHasherConfig() PasswordHasher {
+	if os.Getenv("PASSWORD_HASHER") == "bcrypt" {
+		hasher := &BcryptHasher{Cost: 12}
+		if cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil && cost >= bcrypt.MinCost && cost <= bcrypt.MaxCost {
+			hasher.Cost = cost
+		}
+		return hasher
+	}
+
+	hasher := &Argon2idHasher{Memory: 64 * 1024, Iterations: 3, Parallelism: 2}
+	if memory, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil && memory >= 8*1024 {
+		hasher.Memory = uint32(memory)
+	}
+	if iterations, err := strconv.ParseUint(os.Getenv("ARGON2_ITERATIONS"), 10, 32); err == nil && iterations > 0 {
+		hasher.Iterations = uint32(iterations)
+	}
+	if parallelism, err := strconv.ParseUint(os.Getenv("ARGON2_PARALLELISM"), 10, 8); err == nil && parallelism > 0 {
+		hasher.Parallelism = uint8(parallelism)
+	}
+	return hasher
+}
+
+// passwordHashers are the hashers able to verify stored hashes, whatever hasher is configured for new passwords
+var passwordHashers = []PasswordHasher{&Argon2idHasher{}, &BcryptHasher{}}
+
+// HashPassword hashes the password with the configured hasher
+func HashPassword(password string) (string, error) {
+	return PasswordHasherConfig().Hash(password)
+}
+
+// VerifyPassword returns whether the password matches the encoded hash, whichever hasher produced it
+func VerifyPassword(plainPassword string, hashedPassword string) bool {
+	for _, hasher := range passwordHashers {
+		if !hasher.Handles(hashedPassword) {
+			continue
+		}
+
+		ok, err := hasher.Verify(plainPassword, hashedPassword)
+		return err == nil && ok
+	}
+	return false
+}
+
+// dummyPasswordHash is the hash the passwords of unknown usernames are verified against, hashed on first use with
+// the configured hasher so that it takes as long to verify as the hashes of the users
+var dummyPasswordHash struct {
+	once sync.Once
+	hash string
+}
+
+// VerifyLoginPassword returns whether the password matches the one of the user. The password is still verified
+// against a dummy hash when the user is unknown, so that the response time does not tell whether the username exists.
+func VerifyLoginPassword(plainPassword string, user *User) bool {
+	if user != nil {
+		return VerifyPassword(plainPassword, user.Password)
+	}
+
+	dummyPasswordHash.once.Do(func() {
+		dummyPasswordHash.hash, _ = HashPassword("dummy password")
+	})
+	VerifyPassword(plainPassword, dummyPasswordHash.hash)
+	return false
+}
+
+// PasswordNeedsRehash returns whether the encoded hash was produced by another hasher than the configured one
+// or with other parameters
+func PasswordNeedsRehash(hashedPassword string) bool {
+	hasher := PasswordHasherConfig()
+	return !hasher.Handles(hashedPassword) || hasher.Outdated(hashedPassword)
+}
+
+// UpgradePasswordHash rehashes the password of the user with the configured hasher when its stored hash is
+// outdated, the password must have been verified against the stored hash
+func UpgradePasswordHash(store UserStore, user *User, password string) error {
+	if !PasswordNeedsRehash(user.Password) {
+		return nil
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	err = store.UpdatePasswordHash(&ctx, user.Id, hash)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hash
+	return nil
+}
@@ -0,0 +1,290 @@
+package models
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rules of the password policy violated by a password
+// swagger:response passwordPolicyErrorResponse
+type passwordPolicyErrorResponseWrapper struct {
+	// in:body
+	Body PasswordPolicyError
+}
+
+// PasswordViolation is a rule of the password policy that a password violates
+// swagger:model
+type PasswordViolation struct {
+	// the violated rule, one of min_length, max_length, character_classes, personal_info, breached or history
+	// required:true
+	Rule string `json:"rule"`
+	// the description of the rule for the user
+	// required:true
+	Message string `json:"message"`
+}
+
+type PasswordViolations []PasswordViolation
+
+// PasswordPolicyError is returned when a password violates the password policy, listing every violated rule
+// swagger:model
+type PasswordPolicyError struct {
+	// required:true
+	Message string `json:"message"`
+	// required:true
+	Violations PasswordViolations `json:"violations"`
+}
+
+func (err PasswordPolicyError) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	return e.Encode(err)
+}
+
+// passwordCharacterClasses are the character classes a policy can require, by the name used in PASSWORD_CHARACTER_CLASSES
+var passwordCharacterClasses = map[string]func(r rune) bool{
+	"lower":  unicode.IsLower,
+	"upper":  unicode.IsUpper,
+	"digit":  unicode.IsDigit,
+	"symbol": func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r) },
+}
+
+// minPersonalInfoLength is the length from which a part of the username or of a name is looked for in the password
+const minPersonalInfoLength = 3
+
+// PasswordPolicy defines the rules that passwords must satisfy
+type PasswordPolicy struct {
+	// the minimum number of characters
+	MinLength int
+	// the maximum number of characters
+	MaxLength int
+	// the character classes that must all appear, among lower, upper, digit and symbol
+	CharacterClasses []string
+	// the path of the file listing breached passwords, one per line either in plain text or as the SHA-1 hash
+	// of the password optionally followed by a colon and a count
+	BreachedListPath string
+}
+
+// PasswordPolicyConfig reads the password policy from PASSWORD_MIN_LENGTH (8 by default), PASSWORD_MAX_LENGTH (128),
+// PASSWORD_CHARACTER_CLASSES (comma separated, none by default) and PASSWORD_BREACHED_LIST (no list by default)
+func PasswordPolicyConfig() *PasswordPolicy {
+	policy := &PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        128,
+		BreachedListPath: os.Getenv("PASSWORD_BREACHED_LIST"),
+	}
+
+	if length, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && length > 0 {
+		policy.MinLength = length
+	}
+	if length, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil && length > 0 {
+		policy.MaxLength = length
+	}
+	for _, class := range strings.Split(os.Getenv("PASSWORD_CHARACTER_CLASSES"), ",") {
+		class = strings.TrimSpace(class)
+		if _, ok := passwordCharacterClasses[class]; ok {
+			policy.CharacterClasses = append(policy.CharacterClasses, class)
+		}
+	}
+
+	return policy
+}
+
+// PasswordHistorySize reads from PASSWORD_HISTORY_SIZE how many previous passwords of a user are remembered and can
+// not be reused when the user changes their password, 5 by default and 0 disables the history
+func PasswordHistorySize() int {
+	size, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_SIZE"))
+	if err != nil || size < 0 {
+		return 5
+	}
+	return size
+}
+
+// pushPasswordHistory returns the history with the previous hash in front, capped at PasswordHistorySize
+func pushPasswordHistory(history []string, hash string) []string {
+	size := PasswordHistorySize()
+	history = append([]string{hash}, history...)
+	if len(history) > size {
+		history = history[:size]
+	}
+	return history
+}
+
+// CheckPassword returns every rule of the configured policy that the password of the user violates,
+// the username and the names of the user must not appear in the password
+func CheckPassword(password string, user *User) (PasswordViolations, error) {
+	return PasswordPolicyConfig().Check(password, user)
+}
+
+// Check returns every rule of the policy that the password of the user violates
+func (policy *PasswordPolicy) Check(password string, user *User) (PasswordViolations, error) {
+	violations := PasswordViolations{}
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("must be at least %d characters long", policy.MinLength),
+		})
+	}
+	if length > policy.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    "max_length",
+			Message: fmt.Sprintf("must be at most %d characters long", policy.MaxLength),
+		})
+	}
+
+	missing := []string{}
+	for _, class := range policy.CharacterClasses {
+		if strings.IndexFunc(password, passwordCharacterClasses[class]) < 0 {
+			missing = append(missing, class)
+		}
+	}
+	if len(missing) > 0 {
+		violations = append(violations, PasswordViolation{
+			Rule:    "character_classes",
+			Message: fmt.Sprintf("must contain %s characters", strings.Join(missing, ", ")),
+		})
+	}
+
+	if user != nil && containsPersonalInfo(password, user) {
+		violations = append(violations, PasswordViolation{
+			Rule:    "personal_info",
+			Message: "must not contain the username or a name of the user",
+		})
+	}
+
+	breached, err := isBreachedPassword(policy.BreachedListPath, password)
+	if err != nil {
+		return nil, err
+	}
+	if breached {
+		violations = append(violations, PasswordViolation{
+			Rule:    "breached",
+			Message: "must not be a password known from a data breach",
+		})
+	}
+
+	return violations, nil
+}
+
+// CheckPasswordChange returns every rule of the configured policy that the new password of the user violates,
+// including reusing the current password or one of the PasswordHistorySize previous ones
+func CheckPasswordChange(password string, user *User) (PasswordViolations, error) {
+	violations, err := CheckPassword(password, user)
+	if err != nil {
+		return nil, err
+	}
+
+	size := PasswordHistorySize()
+	history := user.PasswordHistory
+	if len(history) > size {
+		history = history[:size]
+	}
+	for _, hash := range append([]string{user.Password}, history...) {
+		if VerifyPassword(password, hash) {
+			message := "must not be the current password"
+			if size > 0 {
+				message = fmt.Sprintf("must not be the current password or one of the %d previous ones", size)
+			}
+			violations = append(violations, PasswordViolation{Rule: "history", Message: message})
+			break
+		}
+	}
+
+	return violations, nil
+}
+
+// containsPersonalInfo returns whether the password contains, ignoring case and diacritics, the username or a word
+// of the names of the user that is at least minPersonalInfoLength characters long
+func containsPersonalInfo(password string, user *User) bool {
+	normalized := normalizeSearchText(password)
+
+	parts := []string{user.Username, user.FirstName}
+	if user.MiddleName != nil {
+		parts = append(parts, *user.MiddleName)
+	}
+	if user.LastName != nil {
+		parts = append(parts, *user.LastName)
+	}
+
+	for _, part := range parts {
+		for _, word := range splitSearchText(part) {
+			if utf8.RuneCountInString(word) >= minPersonalInfoLength && strings.Contains(normalized, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// breachedList caches the breached password list of a path, it is only read again when the path changes
+var breachedList struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]bool
+}
+
+// isBreachedPassword returns whether the password or its SHA-1 hash is listed in the file at the path
+func isBreachedPassword(path string, password string) (bool, error) {
+	if path == "" {
+		return false, nil
+	}
+
+	breachedList.mu.Lock()
+	defer breachedList.mu.Unlock()
+
+	if breachedList.path != path {
+		entries, err := readBreachedList(path)
+		if err != nil {
+			return false, err
+		}
+		breachedList.path = path
+		breachedList.entries = entries
+	}
+
+	hash := sha1.Sum([]byte(password))
+	return breachedList.entries[password] || breachedList.entries[strings.ToUpper(hex.EncodeToString(hash[:]))], nil
+}
+
+// readBreachedList reads the entries of a breached password list, SHA-1 hashes are normalized to upper case
+func readBreachedList(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if i := strings.Index(line, ":"); i >= 0 && isSHA1Hex(line[:i]) {
+			line = line[:i]
+		}
+		if isSHA1Hex(line) {
+			line = strings.ToUpper(line)
+		}
+		if line != "" {
+			entries[line] = true
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// violatedRules returns the rules of the violations
+func violatedRules(violations PasswordViolations) []string {
+	rules := []string{}
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	breached := filepath.Join(t.TempDir(), "breached.txt")
+	// the second entry is the SHA-1 hash of Password1! with a count
+	err := os.WriteFile(breached, []byte("Zq8#pLm2vR-leaked\r\n32ca9fc1a0f5b6330e3f4c8c1bbecde9bedb9573:42\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        16,
+		CharacterClasses: []string{"lower", "upper", "digit", "symbol"},
+		BreachedListPath: breached,
+	}
+	lastName := "Lie"
+	user := &User{FirstName: "William", LastName: &lastName, Username: "wscarra"}
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"Zq8#pLm2vR", []string{}},
+		{"Zq8#pL", []string{"min_length"}},
+		{"Zq8#pLm2vRZq8#pLm2vR", []string{"max_length"}},
+		{"zq8pLm2vR", []string{"character_classes"}},
+		{"short", []string{"min_length", "character_classes"}},
+		{"Zq8#WSCARRA", []string{"personal_info"}},
+		{"Zq8#wílliam", []string{"personal_info"}},
+		{"Zq8#lie2vR", []string{"personal_info"}},
+		{"Zq8#pLm2vR-leaked", []string{"max_length", "breached"}},
+		{"Password1!", []string{"breached"}},
+	}
+	for _, test := range tests {
+		violations, err := policy.Check(test.password, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rules := violatedRules(violations); !reflect.DeepEqual(rules, test.want) {
+			t.Errorf("%s: got violations %v, want %v", test.password, rules, test.want)
+		}
+		for _, violation := range violations {
+			if violation.Message == "" {
+				t.Errorf("%s: the %s violation has no message", test.password, violation.Rule)
+			}
+		}
+	}
+
+	// names shorter than the minimum are not looked for
+	violations, err := policy.Check("Zq8#Al2vR", &User{FirstName: "Al", Username: "al"})
+	if err != nil || len(violations) != 0 {
+		t.Errorf("a short name: got violations %v, %v", violatedRules(violations), err)
+	}
+}
+
+func TestPasswordPolicyConfig(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_MAX_LENGTH", "64")
+	t.Setenv("PASSWORD_CHARACTER_CLASSES", "upper, digit,unknown")
+	t.Setenv("PASSWORD_BREACHED_LIST", "")
+
+	policy := PasswordPolicyConfig()
+	want := &PasswordPolicy{MinLength: 12, MaxLength: 64, CharacterClasses: []string{"upper", "digit"}}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("got policy %+v, want %+v", policy, want)
+	}
+}
+
+func TestPasswordHistoryMessage(t *testing.T) {
+	t.Setenv("ARGON2_MEMORY", "8192")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	hash, err := HashPassword("Zq8#pLm2vR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{FirstName: "Joshua", Username: "joshua", Password: hash}
+
+	for size, want := range map[string]string{
+		"0": "must not be the current password",
+		"3": "must not be the current password or one of the 3 previous ones",
+	} {
+		t.Setenv("PASSWORD_HISTORY_SIZE", size)
+		violations, err := CheckPasswordChange("Zq8#pLm2vR", user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(violations) != 1 || violations[0].Message != want {
+			t.Errorf("reusing the current password with a history of %s: got %v, want %q", size, violations, want)
+		}
+	}
+}
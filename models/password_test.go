@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := []PasswordHasher{
+		&Argon2idHasher{Memory: 8 * 1024, Iterations: 1, Parallelism: 1},
+		&BcryptHasher{Cost: 4},
+	}
+	for _, hasher := range hashers {
+		hash, err := hasher.Hash("Zq8#pLm2vR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasher.Handles(hash) || hasher.Outdated(hash) {
+			t.Errorf("%T does not handle its own hash %s", hasher, hash)
+		}
+		if !VerifyPassword("Zq8#pLm2vR", hash) {
+			t.Errorf("%T: the password does not verify against its hash %s", hasher, hash)
+		}
+		if VerifyPassword("wrong", hash) {
+			t.Errorf("%T: a wrong password verifies against the hash %s", hasher, hash)
+		}
+	}
+
+	hash, err := hashers[0].Hash("Zq8#pLm2vR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("the argon2id hash does not encode its parameters: %s", hash)
+	}
+	if !(&Argon2idHasher{Memory: 8 * 1024, Iterations: 2, Parallelism: 1}).Outdated(hash) {
+		t.Errorf("a hash with fewer iterations is not outdated")
+	}
+	if VerifyPassword("Zq8#pLm2vR", "plain") {
+		t.Errorf("a hash of no known hasher verifies")
+	}
+}
+
+func TestUpgradePasswordHash(t *testing.T) {
+	t.Setenv("ARGON2_MEMORY", "8192")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+
+	t.Setenv("PASSWORD_HASHER", "bcrypt")
+	t.Setenv("BCRYPT_COST", "4")
+	id, err := store.CreateUser(&ctx, User{Roles: []UserRole{General}, FirstName: "Joshua", Username: "joshua", Password: "Zq8#pLm2vR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.Password, "$2a$04$") {
+		t.Fatalf("the password was not hashed with the configured bcrypt cost: %s", user.Password)
+	}
+
+	// the bcrypt hash is upgraded once argon2id is configured
+	t.Setenv("PASSWORD_HASHER", "")
+	if !PasswordNeedsRehash(user.Password) {
+		t.Fatalf("the bcrypt hash does not need a rehash under argon2id")
+	}
+	err = UpgradePasswordHash(store, user, "Zq8#pLm2vR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored.Password, "$argon2id$") || stored.Password != user.Password {
+		t.Errorf("the hash was not upgraded to argon2id: %s", stored.Password)
+	}
+	if !VerifyPassword("Zq8#pLm2vR", stored.Password) {
+		t.Errorf("the password does not verify against the upgraded hash")
+	}
+
+	// an up to date hash is kept
+	err = UpgradePasswordHash(store, stored, "Zq8#pLm2vR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Password != stored.Password {
+		t.Errorf("an up to date hash was rehashed")
+	}
+}
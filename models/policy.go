@@ -0,0 +1,555 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The decision taken on an authorization request
+// swagger:response authzDecisionResponse
+type authzDecisionResponseWrapper struct {
+	// in:body
+	Body AuthzDecision
+}
+
+// swagger:parameters checkAuthz
+type authzCheckParameterWrapper struct {
+	// The subject, the action and the resource of the authorization request to decide
+	// in:body
+	// required:true
+	Body AuthzCheck
+}
+
+// PolicyEffect is whether a policy allows or denies the actions it applies to
+type PolicyEffect string
+
+const (
+	PolicyAllow PolicyEffect = "allow"
+	PolicyDeny  PolicyEffect = "deny"
+)
+
+// PolicyAnyAction makes a policy apply to every action
+const PolicyAnyAction Permission = "*"
+
+// policySubjectReference is the prefix of the values of a resource condition that refer to an attribute of the
+// subject, e.g. $subject.attributes.organization
+const policySubjectReference = "$subject."
+
+// Attributes are the attributes of a subject or a resource by name, each holding any number of values: id, roles,
+// username (resources only) and attributes.<name> for the attributes set on a user
+type Attributes map[string][]string
+
+// AttributeCondition restricts an attribute to hold one of the values of In, when given, and none of the values of
+// NotIn. In resource conditions, a value starting with $subject. is replaced by the values of that attribute of the
+// subject.
+type AttributeCondition struct {
+	In    []string `json:"in,omitempty"`
+	NotIn []string `json:"not_in,omitempty"`
+}
+
+// AttributeConditions are the conditions an entity must all satisfy, by attribute name
+type AttributeConditions map[string]AttributeCondition
+
+// Policy allows or denies actions to the subjects and on the resources satisfying its conditions
+type Policy struct {
+	// the unique ID of the policy, reported in the decisions it takes
+	Id          string       `json:"id"`
+	Description string       `json:"description"`
+	Effect      PolicyEffect `json:"effect"`
+	// the permissions the policy applies to, * for every one of them
+	Actions  []Permission        `json:"actions"`
+	Subject  AttributeConditions `json:"subject"`
+	Resource AttributeConditions `json:"resource"`
+}
+
+// PolicySet is the content of the policy file. A deny policy that applies overrides everything, otherwise the action
+// is allowed when the roles of the subject grant it or an allow policy applies.
+type PolicySet struct {
+	Policies []*Policy `json:"policies"`
+}
+
+// AuthzDecision is the decision taken on an authorization request
+// swagger:model
+type AuthzDecision struct {
+	// whether the action is allowed
+	// required:true
+	Allowed bool `json:"allowed"`
+	// the ID of the policy that took the decision, omitted when the roles of the subject or no policy took it
+	Policy string `json:"policy,omitempty"`
+	// why the action is allowed or denied
+	// required:true
+	Reason string `json:"reason"`
+}
+
+// AuthzSubject defines the subject of an authorization request
+// swagger:model
+type AuthzSubject struct {
+	// the ID of the subject
+	Id string `json:"id"`
+	// the names of the roles of the subject, resolved into permissions
+	Roles []UserRole `json:"roles"`
+	// the attributes set on the subject
+	Attributes map[string]string `json:"attributes"`
+}
+
+// AuthzResource defines the user that an authorization request acts on
+// swagger:model
+type AuthzResource struct {
+	// the ID of the user
+	Id string `json:"id"`
+	// the names of the roles of the user
+	Roles []UserRole `json:"roles"`
+	// the username of the user
+	Username string `json:"username"`
+	// the attributes set on the user
+	Attributes map[string]string `json:"attributes"`
+}
+
+// AuthzCheck defines an authorization request to decide without performing it
+// swagger:model
+type AuthzCheck struct {
+	// required:true
+	Subject AuthzSubject `json:"subject"`
+	// the permission the subject uses
+	// required:true
+	Action Permission `json:"action"`
+	// the user the subject acts on, omitted for the actions that do not act on a single user
+	Resource *AuthzResource `json:"resource"`
+}
+
+// PolicyFile reads from POLICY_FILE the path of the policy file, no policies apply when it is not set
+func PolicyFile() string {
+	return os.Getenv("POLICY_FILE")
+}
+
+// PolicyReloadInterval reads from POLICY_RELOAD_INTERVAL how often, in seconds, the policy file is checked for
+// changes, 5 seconds by default
+func PolicyReloadInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("POLICY_RELOAD_INTERVAL"))
+	if err != nil || seconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// currentPolicies are the policies in effect, replaced as a whole whenever the policy file changes
+var currentPolicies struct {
+	mu  sync.RWMutex
+	set *PolicySet
+}
+
+// CurrentPolicies returns the policies in effect, an empty set until policies are loaded
+func CurrentPolicies() *PolicySet {
+	currentPolicies.mu.RLock()
+	defer currentPolicies.mu.RUnlock()
+
+	if currentPolicies.set == nil {
+		return &PolicySet{}
+	}
+	return currentPolicies.set
+}
+
+// UsePolicies puts the policies in effect
+func UsePolicies(set *PolicySet) {
+	currentPolicies.mu.Lock()
+	defer currentPolicies.mu.Unlock()
+
+	currentPolicies.set = set
+}
+
+// LoadPolicies reads and validates the policy file at the path
+func LoadPolicies(path string) (*PolicySet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	set := &PolicySet{}
+	err = set.FromJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPolicy, err)
+	}
+	err = set.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// WatchPolicies loads the policy file at the path and reloads it whenever its modification time or size changes until
+// the context is done, a file that fails to load on reload is logged and the previous policies stay in effect
+func WatchPolicies(ctx context.Context, path string, interval time.Duration, l *log.Logger) error {
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	set, err := LoadPolicies(path)
+	if err != nil {
+		return err
+	}
+	UsePolicies(set)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			changed, err := os.Stat(path)
+			if err != nil {
+				l.Printf("Error checking the policy file: %s", err)
+				continue
+			}
+			if changed.ModTime().Equal(info.ModTime()) && changed.Size() == info.Size() {
+				continue
+			}
+			info = changed
+
+			set, err := LoadPolicies(path)
+			if err != nil {
+				l.Printf("Error reloading the policy file, keeping the previous policies: %s", err)
+				continue
+			}
+			UsePolicies(set)
+			l.Printf("Reloaded %d policies from %s", len(set.Policies), path)
+		}
+	}()
+	return nil
+}
+
+func (set *PolicySet) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	e.DisallowUnknownFields()
+	return e.Decode(set)
+}
+
+// policyAttributePattern matches the attribute names of the conditions
+var policyAttributePattern = regexp.MustCompile(`^(id|roles|username|attributes\.[a-z][a-z0-9_]{0,31})$`)
+
+// Validate returns ErrInvalidPolicy when a policy has no or a duplicate ID, an unknown effect or action, or a
+// condition on an unknown attribute
+func (set *PolicySet) Validate() error {
+	ids := map[string]bool{}
+	for i, policy := range set.Policies {
+		if policy == nil || policy.Id == "" {
+			return fmt.Errorf("%w: policy %d has no id", ErrInvalidPolicy, i)
+		}
+		if ids[policy.Id] {
+			return fmt.Errorf("%w: duplicate id %s", ErrInvalidPolicy, policy.Id)
+		}
+		ids[policy.Id] = true
+
+		if policy.Effect != PolicyAllow && policy.Effect != PolicyDeny {
+			return fmt.Errorf("%w: %s has an unknown effect %q", ErrInvalidPolicy, policy.Id, policy.Effect)
+		}
+		if len(policy.Actions) == 0 {
+			return fmt.Errorf("%w: %s has no actions", ErrInvalidPolicy, policy.Id)
+		}
+		for _, action := range policy.Actions {
+			if action != PolicyAnyAction && !AllPermissions.Has(action) {
+				return fmt.Errorf("%w: %s has an unknown action %q", ErrInvalidPolicy, policy.Id, action)
+			}
+		}
+
+		for name := range policy.Subject {
+			if !policyAttributePattern.MatchString(name) || name == "username" {
+				return fmt.Errorf("%w: %s has a condition on an unknown subject attribute %q", ErrInvalidPolicy, policy.Id, name)
+			}
+		}
+		for name, condition := range policy.Resource {
+			if !policyAttributePattern.MatchString(name) {
+				return fmt.Errorf("%w: %s has a condition on an unknown resource attribute %q", ErrInvalidPolicy, policy.Id, name)
+			}
+			for _, value := range append(append([]string{}, condition.In...), condition.NotIn...) {
+				if strings.HasPrefix(value, policySubjectReference) {
+					reference := strings.TrimPrefix(value, policySubjectReference)
+					if !policyAttributePattern.MatchString(reference) || reference == "username" {
+						return fmt.Errorf("%w: %s refers to an unknown subject attribute %q", ErrInvalidPolicy, policy.Id, value)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// PolicySubject is the client whose access is decided
+type PolicySubject struct {
+	Attributes  Attributes
+	Permissions Permissions
+	// the permissions a personal access token limits the subject to, nil when the subject authenticated otherwise
+	Scopes Permissions
+}
+
+// SubjectFromContext returns the client authenticated in the context
+func SubjectFromContext(ctx context.Context) PolicySubject {
+	userId, _ := ctx.Value("user_id").(string)
+	roles, _ := ctx.Value("user_roles").([]UserRole)
+	attributes, _ := ctx.Value("user_attributes").(map[string]string)
+	permissions, _ := ctx.Value("user_permissions").(Permissions)
+	scopes, _ := ctx.Value("token_scopes").(Permissions)
+
+	return PolicySubject{
+		Attributes:  entityAttributes(userId, roles, "", attributes),
+		Permissions: permissions,
+		Scopes:      scopes,
+	}
+}
+
+// PolicyAttributes returns the attributes of the user as a resource
+func (user *User) PolicyAttributes() Attributes {
+	return entityAttributes(user.Id.Hex(), user.Roles, user.Username, user.Attributes)
+}
+
+// entityAttributes returns the attributes of a subject or a resource, omitting the empty ones
+func entityAttributes(id string, roles []UserRole, username string, attributes map[string]string) Attributes {
+	result := Attributes{}
+	if id != "" {
+		result["id"] = []string{id}
+	}
+	if len(roles) > 0 {
+		result["roles"] = []string{}
+		for _, role := range roles {
+			result["roles"] = append(result["roles"], string(role))
+		}
+	}
+	if username != "" {
+		result["username"] = []string{username}
+	}
+	for name, value := range attributes {
+		result["attributes."+name] = []string{value}
+	}
+	return result
+}
+
+// appliesTo returns whether the policy applies to the action of the subject, whatever the resource
+func (policy *Policy) appliesTo(subject Attributes, action Permission) bool {
+	applies := false
+	for _, a := range policy.Actions {
+		if a == action || a == PolicyAnyAction {
+			applies = true
+		}
+	}
+	return applies && policy.Subject.matches(subject, nil)
+}
+
+// matches returns whether the attributes satisfy every condition, references are resolved against the subject
+func (conditions AttributeConditions) matches(attributes Attributes, subject Attributes) bool {
+	for name, condition := range conditions {
+		values := attributes[name]
+		if condition.In != nil && !intersects(values, resolveReferences(condition.In, subject)) {
+			return false
+		}
+		if intersects(values, resolveReferences(condition.NotIn, subject)) {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns the MongoDB predicate of the conditions on users, it must agree with matches
+func (conditions AttributeConditions) match(subject Attributes) bson.M {
+	match := bson.M{}
+	for name, condition := range conditions {
+		field := name
+		if name == "id" {
+			field = "_id"
+		}
+
+		predicate := bson.M{}
+		if condition.In != nil {
+			predicate["$in"] = policyFieldValues(name, resolveReferences(condition.In, subject))
+		}
+		if len(condition.NotIn) > 0 {
+			predicate["$nin"] = policyFieldValues(name, resolveReferences(condition.NotIn, subject))
+		}
+		if len(predicate) > 0 {
+			match[field] = predicate
+		}
+	}
+	return match
+}
+
+// policyFieldValues returns the values as they are stored in the field of the attribute
+func policyFieldValues(name string, values []string) bson.A {
+	result := bson.A{}
+	for _, value := range values {
+		if name == "id" {
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				continue
+			}
+			result = append(result, id)
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+// resolveReferences replaces the references to attributes of the subject with their values
+func resolveReferences(values []string, subject Attributes) []string {
+	resolved := []string{}
+	for _, value := range values {
+		if strings.HasPrefix(value, policySubjectReference) {
+			resolved = append(resolved, subject[strings.TrimPrefix(value, policySubjectReference)]...)
+			continue
+		}
+		resolved = append(resolved, value)
+	}
+	return resolved
+}
+
+func intersects(values []string, others []string) bool {
+	for _, value := range values {
+		for _, other := range others {
+			if value == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decide returns whether the subject may perform the action on the resource, a nil resource when the action does not
+// act on a single user. Policies with resource conditions only apply when there is a resource.
+func (set *PolicySet) Decide(subject PolicySubject, action Permission, resource Attributes) AuthzDecision {
+	applies := func(policy *Policy) bool {
+		if len(policy.Resource) == 0 {
+			return true
+		}
+		return resource != nil && policy.Resource.matches(resource, subject.Attributes)
+	}
+	return set.decide(subject, action, applies)
+}
+
+// DecideList returns whether the subject may perform the action on some of the users it lists, the users the
+// policies hide are filtered out by the visibility rules of GetUsers
+func (set *PolicySet) DecideList(subject PolicySubject, action Permission) AuthzDecision {
+	return set.decide(subject, action, func(policy *Policy) bool {
+		return policy.Effect == PolicyAllow || len(policy.Resource) == 0
+	})
+}
+
+func (set *PolicySet) decide(subject PolicySubject, action Permission, applies func(policy *Policy) bool) AuthzDecision {
+	if subject.Scopes != nil && !subject.Scopes.Has(action) {
+		return AuthzDecision{Allowed: false, Reason: "outside the scopes of the personal access token"}
+	}
+	for _, policy := range set.Policies {
+		if policy.Effect == PolicyDeny && policy.appliesTo(subject.Attributes, action) && applies(policy) {
+			return AuthzDecision{Allowed: false, Policy: policy.Id, Reason: "denied by a policy"}
+		}
+	}
+	if subject.Permissions.Has(action) {
+		return AuthzDecision{Allowed: true, Reason: "granted by the roles of the subject"}
+	}
+	for _, policy := range set.Policies {
+		if policy.Effect == PolicyAllow && policy.appliesTo(subject.Attributes, action) && applies(policy) {
+			return AuthzDecision{Allowed: true, Policy: policy.Id, Reason: "allowed by a policy"}
+		}
+	}
+	return AuthzDecision{Allowed: false, Reason: "neither the roles of the subject nor a policy allow the action"}
+}
+
+// visibleMatch returns the MongoDB predicate of the users the subject may perform the action on, nil when the
+// policies do not restrict the subject, it must agree with Decide
+func (set *PolicySet) visibleMatch(subject PolicySubject, action Permission) bson.M {
+	if subject.Scopes != nil && !subject.Scopes.Has(action) {
+		return bson.M{"_id": bson.M{"$in": bson.A{}}}
+	}
+
+	conditions := bson.A{}
+
+	if !subject.Permissions.Has(action) {
+		allowed := bson.A{}
+		for _, policy := range set.Policies {
+			if policy.Effect != PolicyAllow || !policy.appliesTo(subject.Attributes, action) {
+				continue
+			}
+			if len(policy.Resource) == 0 {
+				allowed = nil
+				break
+			}
+			allowed = append(allowed, policy.Resource.match(subject.Attributes))
+		}
+		switch {
+		case allowed == nil:
+		case len(allowed) == 0:
+			// no user at all, MongoDB refuses an empty $or
+			conditions = append(conditions, bson.M{"_id": bson.M{"$in": bson.A{}}})
+		default:
+			conditions = append(conditions, bson.M{"$or": allowed})
+		}
+	}
+
+	denied := bson.A{}
+	for _, policy := range set.Policies {
+		if policy.Effect == PolicyDeny && policy.appliesTo(subject.Attributes, action) {
+			denied = append(denied, policy.Resource.match(subject.Attributes))
+		}
+	}
+	if len(denied) > 0 {
+		conditions = append(conditions, bson.M{"$nor": denied})
+	}
+
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0].(bson.M)
+	default:
+		return bson.M{"$and": conditions}
+	}
+}
+
+// CheckAuthz decides the authorization request under the policies in effect, the permissions of the subject are
+// resolved from its roles
+func CheckAuthz(roles RoleStore, check AuthzCheck) (*AuthzDecision, error) {
+	permissions, err := ResolvePermissions(roles, check.Subject.Roles)
+	if err != nil {
+		return nil, err
+	}
+	subject := PolicySubject{
+		Attributes:  entityAttributes(check.Subject.Id, check.Subject.Roles, "", check.Subject.Attributes),
+		Permissions: permissions,
+	}
+
+	var resource Attributes
+	if check.Resource != nil {
+		resource = entityAttributes(check.Resource.Id, check.Resource.Roles, check.Resource.Username, check.Resource.Attributes)
+	}
+
+	decision := CurrentPolicies().Decide(subject, check.Action, resource)
+	return &decision, nil
+}
+
+func (check *AuthzCheck) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(check)
+}
+
+func (decision *AuthzDecision) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, decision)
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchPoliciesStopsWithItsContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	write := func(id string) {
+		t.Helper()
+		err := os.WriteFile(path, []byte(`{"policies": [{"id": "`+id+`", "effect": "allow", "actions": ["*"]}]}`), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := func() string {
+		return CurrentPolicies().Policies[0].Id
+	}
+	t.Cleanup(func() { UsePolicies(&PolicySet{}) })
+
+	write("first")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interval := 10 * time.Millisecond
+	err := WatchPolicies(ctx, path, interval, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current() != "first" {
+		t.Fatalf("the policy file was not loaded: got %s", current())
+	}
+
+	write("second-policy")
+	deadline := time.Now().Add(2 * time.Second)
+	for current() != "second-policy" && time.Now().Before(deadline) {
+		time.Sleep(interval)
+	}
+	if current() != "second-policy" {
+		t.Fatalf("the changed policy file was not reloaded: got %s", current())
+	}
+
+	cancel()
+	time.Sleep(3 * interval)
+	write("third-policy-file")
+	time.Sleep(10 * interval)
+	if current() != "second-policy" {
+		t.Errorf("the policy file was reloaded after the context was done: got %s", current())
+	}
+}
+
+func TestPolicySetValidate(t *testing.T) {
+	valid := func() *Policy {
+		return &Policy{
+			Id:       "same-organization",
+			Effect:   PolicyAllow,
+			Actions:  []Permission{PermissionUsersRead},
+			Subject:  AttributeConditions{"roles": {In: []string{string(General)}}},
+			Resource: AttributeConditions{"attributes.organization": {In: []string{"$subject.attributes.organization"}}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(policy *Policy)
+		valid  bool
+	}{
+		{"valid", func(policy *Policy) {}, true},
+		{"every action", func(policy *Policy) { policy.Actions = []Permission{PolicyAnyAction} }, true},
+		{"no id", func(policy *Policy) { policy.Id = "" }, false},
+		{"unknown effect", func(policy *Policy) { policy.Effect = "maybe" }, false},
+		{"no actions", func(policy *Policy) { policy.Actions = nil }, false},
+		{"unknown action", func(policy *Policy) { policy.Actions = []Permission{"users:fly"} }, false},
+		{"subject username", func(policy *Policy) { policy.Subject = AttributeConditions{"username": {In: []string{"bob"}}} }, false},
+		{"unknown resource attribute", func(policy *Policy) { policy.Resource = AttributeConditions{"age": {In: []string{"1"}}} }, false},
+		{"unknown subject reference", func(policy *Policy) {
+			policy.Resource = AttributeConditions{"id": {In: []string{"$subject.username"}}}
+		}, false},
+	}
+	for _, test := range tests {
+		policy := valid()
+		test.change(policy)
+		err := (&PolicySet{Policies: []*Policy{policy}}).Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("%s: got %v, want %v", test.name, err, ErrInvalidPolicy)
+		}
+	}
+
+	err := (&PolicySet{Policies: []*Policy{valid(), valid()}}).Validate()
+	if !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("duplicate ids: got %v, want %v", err, ErrInvalidPolicy)
+	}
+}
+
+func TestPolicySetDecide(t *testing.T) {
+	set := &PolicySet{Policies: []*Policy{{
+		Id:       "same-organization",
+		Effect:   PolicyAllow,
+		Actions:  []Permission{PermissionUsersRead},
+		Resource: AttributeConditions{"attributes.organization": {In: []string{"$subject.attributes.organization"}}},
+	}, {
+		Id:       "protected-users",
+		Effect:   PolicyDeny,
+		Actions:  []Permission{PolicyAnyAction},
+		Subject:  AttributeConditions{"roles": {NotIn: []string{string(Admin)}}},
+		Resource: AttributeConditions{"attributes.protected": {In: []string{"true"}}},
+	}}}
+	member := PolicySubject{Attributes: entityAttributes("member", []UserRole{General}, "", map[string]string{"organization": "acme"})}
+	reader := PolicySubject{
+		Attributes:  entityAttributes("reader", []UserRole{"Reader"}, "", nil),
+		Permissions: Permissions{PermissionUsersRead},
+	}
+	colleague := entityAttributes("colleague", []UserRole{General}, "colleague", map[string]string{"organization": "acme"})
+	outsider := entityAttributes("outsider", []UserRole{General}, "outsider", map[string]string{"organization": "other"})
+	protected := entityAttributes("protected", []UserRole{General}, "protected", map[string]string{"organization": "acme", "protected": "true"})
+
+	tests := []struct {
+		name     string
+		subject  PolicySubject
+		resource Attributes
+		allowed  bool
+		policy   string
+	}{
+		{"allowed by a policy", member, colleague, true, "same-organization"},
+		{"outside of the policy", member, outsider, false, ""},
+		{"without a resource", member, nil, false, ""},
+		{"granted by the roles", reader, outsider, true, ""},
+		{"denied over the policy", member, protected, false, "protected-users"},
+		{"denied over the roles", reader, protected, false, "protected-users"},
+	}
+	for _, test := range tests {
+		decision := set.Decide(test.subject, PermissionUsersRead, test.resource)
+		if decision.Allowed != test.allowed || decision.Policy != test.policy {
+			t.Errorf("%s: got %+v, want allowed %t by %q", test.name, decision, test.allowed, test.policy)
+		}
+	}
+
+	// the policies with resource conditions that allow the action let the subject list the users they apply to
+	if !set.DecideList(member, PermissionUsersRead).Allowed {
+		t.Errorf("listing the users of the organization is not allowed")
+	}
+	if set.DecideList(member, PermissionUsersWrite).Allowed {
+		t.Errorf("listing the users for an action no policy allows is allowed")
+	}
+}
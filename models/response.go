@@ -0,0 +1,72 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Response is a value that can be written as the body of a response
+type Response interface {
+	ToJSON(w io.Writer) error
+}
+
+// sensitiveFields are the JSON fields that must never be written to a response encoded by EncodeResponse,
+// only the login response and a created personal access token may carry tokens
+var sensitiveFields = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"refresh_token": true,
+	"token_hash":    true,
+}
+
+// EncodeResponse writes the value as JSON, refusing to write anything when the value contains a sensitive field
+func EncodeResponse(w io.Writer, v interface{}) error {
+	buf := bytes.Buffer{}
+	err := json.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return err
+	}
+
+	err = checkSensitiveFields(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// checkSensitiveFields returns ErrSensitiveField when any object in the encoded JSON has a sensitive field
+func checkSensitiveFields(data []byte) error {
+	var decoded interface{}
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for key, field := range value {
+				if sensitiveFields[key] {
+					return fmt.Errorf("%w: %s", ErrSensitiveField, key)
+				}
+				if err := walk(field); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, item := range value {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return walk(decoded)
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sensitiveUser returns a user with every field set, the password and its history included
+func sensitiveUser() *User {
+	middleName := "Middle"
+	lastName := "Last"
+	email := "user@example.com"
+	phone := "+6281234567890"
+	maxSessions := 3
+	deletedAt := time.Now()
+
+	return &User{
+		Id:              primitive.NewObjectID(),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		DeletedAt:       &deletedAt,
+		Roles:           []UserRole{Admin},
+		FirstName:       "First",
+		MiddleName:      &middleName,
+		LastName:        &lastName,
+		Username:        "user",
+		Password:        "$2a$10$hash",
+		Email:           &email,
+		Phone:           &phone,
+		Preferences:     UserPreferences{Language: "id", Timezone: "Asia/Jakarta"},
+		Attributes:      map[string]string{"organization": "sejutacita"},
+		MaxSessions:     &maxSessions,
+		PasswordHistory: []string{"$2a$10$previous"},
+		SearchTerms:     []string{"first", "user"},
+		RoleKey:         "Admin",
+		Score:           1,
+	}
+}
+
+// encodedKeys returns every object key in the encoded JSON, at any depth
+func encodedKeys(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+
+	var decoded interface{}
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("decoding the response: %s", err)
+	}
+
+	keys := map[string]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for key, field := range value {
+				keys[key] = true
+				walk(field)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(decoded)
+	return keys
+}
+
+func TestResponsesHoldNoSensitiveField(t *testing.T) {
+	user := sensitiveUser()
+	lastUsedAt := time.Now()
+	accessToken := &AccessToken{
+		Id:         primitive.NewObjectID().Hex(),
+		UserId:     user.Id.Hex(),
+		Name:       "ci",
+		Scopes:     Permissions{PermissionUsersRead},
+		Hash:       hashAccessToken(accessTokenPrefix + "secret"),
+		Prefix:     accessTokenPrefix + "secret",
+		CreatedAt:  time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+		LastUsedAt: &lastUsedAt,
+	}
+
+	responses := map[string]Response{
+		"UserPublicView":     user.PublicView(),
+		"UserSelfView":       user.SelfView(),
+		"UserAdminView":      user.AdminView(),
+		"UserAdminViews":     Users{user}.AdminViews(),
+		"UserAdminViewsPage": (&UserPage{Users: Users{user}, NextCursor: "cursor"}).AdminViews(),
+		"Role":               &Role{Name: Admin, Permissions: AllPermissions},
+		"Roles":              Roles{{Name: Admin, Permissions: AllPermissions}},
+		"Sessions":           Sessions{{Id: primitive.NewObjectID().Hex(), UserId: user.Id.Hex(), Device: "laptop"}},
+		"AccessTokens":       AccessTokens{accessToken},
+	}
+
+	for name, response := range responses {
+		buf := bytes.Buffer{}
+		err := response.ToJSON(&buf)
+		if err != nil {
+			t.Errorf("%s: encoding the response: %s", name, err)
+			continue
+		}
+
+		keys := encodedKeys(t, buf.Bytes())
+		for field := range sensitiveFields {
+			if keys[field] {
+				t.Errorf("%s: the response holds the sensitive field %s", name, field)
+			}
+		}
+		if bytes.Contains(buf.Bytes(), []byte(user.Password)) || bytes.Contains(buf.Bytes(), []byte(accessToken.Hash)) {
+			t.Errorf("%s: the response holds a password or token hash", name)
+		}
+	}
+}
+
+// TestCreatedAccessTokenHoldsOnlyTheToken checks the one response besides the login that carries a token
+func TestCreatedAccessTokenHoldsOnlyTheToken(t *testing.T) {
+	token := accessTokenPrefix + "secret"
+	created := &CreatedAccessToken{
+		AccessToken: AccessToken{Id: primitive.NewObjectID().Hex(), Hash: hashAccessToken(token), Scopes: Permissions{}},
+		Token:       token,
+	}
+
+	buf := bytes.Buffer{}
+	err := created.ToJSON(&buf)
+	if err != nil {
+		t.Fatalf("encoding the response: %s", err)
+	}
+
+	keys := encodedKeys(t, buf.Bytes())
+	for _, field := range []string{"password", "refresh_token", "token_hash", "hash"} {
+		if keys[field] {
+			t.Errorf("the response holds the sensitive field %s", field)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte(created.Hash)) {
+		t.Errorf("the response holds the token hash")
+	}
+}
+
+func TestEncodeResponseRefusesSensitiveFields(t *testing.T) {
+	for field := range sensitiveFields {
+		buf := bytes.Buffer{}
+		err := EncodeResponse(&buf, []map[string]interface{}{{"nested": map[string]string{field: "secret"}}})
+		if !errors.Is(err, ErrSensitiveField) {
+			t.Errorf("%s: got error %v, want ErrSensitiveField", field, err)
+		}
+		if buf.Len() > 0 {
+			t.Errorf("%s: the response was written", field)
+		}
+	}
+
+	// the raw user carries its password hash and must never be encoded as a response
+	err := EncodeResponse(&bytes.Buffer{}, sensitiveUser())
+	if !errors.Is(err, ErrSensitiveField) {
+		t.Errorf("User: got error %v, want ErrSensitiveField", err)
+	}
+}
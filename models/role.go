@@ -0,0 +1,395 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// The roles that are returned in the response
+// swagger:response rolesResponse
+type rolesResponseWrapper struct {
+	// in:body
+	Body Roles
+}
+
+// A role that is returned in the response
+// swagger:response roleResponse
+type roleResponseWrapper struct {
+	// in:body
+	Body Role
+}
+
+// swagger:parameters getRole updateRole deleteRole
+type roleNameParameterWrapper struct {
+	// The name of the role to perform the operation on
+	// in:query
+	// required:true
+	Name string `json:"name"`
+}
+
+// swagger:parameters createRole updateRole
+type roleParameterWrapper struct {
+	// The role, the name in the body is ignored on update
+	// in:body
+	// required:true
+	Body Role
+}
+
+// Permission is the right to perform a kind of operation, the routes each declare the permission they need
+type Permission string
+
+const (
+	// PermissionUsersRead allows reading any user and listing users
+	PermissionUsersRead Permission = "users:read"
+	// PermissionUsersWrite allows creating and updating any user and unlocking users
+	PermissionUsersWrite Permission = "users:write"
+	// PermissionUsersDelete allows deleting, restoring and purging users
+	PermissionUsersDelete Permission = "users:delete"
+	// PermissionSessionsRead allows listing the sessions of any user
+	PermissionSessionsRead Permission = "sessions:read"
+	// PermissionSessionsRevoke allows revoking the sessions of any user
+	PermissionSessionsRevoke Permission = "sessions:revoke"
+	// PermissionRolesRead allows listing the roles
+	PermissionRolesRead Permission = "roles:read"
+	// PermissionRolesWrite allows creating, updating and deleting roles
+	PermissionRolesWrite Permission = "roles:write"
+	// PermissionPoliciesCheck allows testing the decisions of the access policies
+	PermissionPoliciesCheck Permission = "policies:check"
+)
+
+// AllPermissions are the permissions that roles can grant
+var AllPermissions = Permissions{
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersDelete,
+	PermissionSessionsRead,
+	PermissionSessionsRevoke,
+	PermissionRolesRead,
+	PermissionRolesWrite,
+	PermissionPoliciesCheck,
+}
+
+type Permissions []Permission
+
+// Has returns whether the permission is among the permissions
+func (permissions Permissions) Has(permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission returns whether the client in the context was granted the permission by any of its roles
+func HasPermission(ctx context.Context, permission Permission) bool {
+	permissions, _ := ctx.Value("user_permissions").(Permissions)
+	return permissions.Has(permission)
+}
+
+// Role defines a named set of permissions that users hold
+// swagger:model
+type Role struct {
+	// the name of the role, held by users
+	// required:true
+	Name UserRole `bson:"_id"         json:"name"        validate:"role_name"`
+	// the description of the role
+	Description string `bson:"description" json:"description"`
+	// the permissions granted to the users holding the role
+	// required:true
+	Permissions Permissions `bson:"permissions" json:"permissions" validate:"permissions"`
+	// whether the role is one of the built-in roles, which can not be deleted and of which Admin can not be changed
+	BuiltIn bool `bson:"built_in"    json:"built_in"`
+	// the date the role was created at
+	CreatedAt time.Time `bson:"created_at"  json:"created_at"`
+	// the date the role was last updated at
+	UpdatedAt time.Time `bson:"updated_at"  json:"updated_at"`
+}
+
+type Roles []*Role
+
+// RoleStore defines the persistence operations on roles
+type RoleStore interface {
+	// GetRoles returns every role sorted by name
+	GetRoles(ctx *context.Context) (Roles, error)
+	// GetRolesByName returns the roles among the names that exist
+	GetRolesByName(ctx *context.Context, names []UserRole) (Roles, error)
+	// CreateRole returns ErrDuplicateRole when a role with the name already exists
+	CreateRole(ctx *context.Context, role Role) error
+	// UpdateRole replaces the description and the permissions of the role
+	UpdateRole(ctx *context.Context, role Role) error
+	DeleteRole(ctx *context.Context, name UserRole) error
+}
+
+// BuiltInRoles returns the roles that always exist: Admin, which is granted every permission including the ones
+// added later, and General, which is granted none by default
+func BuiltInRoles() Roles {
+	return Roles{
+		{
+			Name:        Admin,
+			Description: "administers users, sessions and roles",
+			Permissions: AllPermissions,
+			BuiltIn:     true,
+		},
+		{
+			Name:        General,
+			Description: "manages their own user and sessions",
+			Permissions: Permissions{},
+			BuiltIn:     true,
+		},
+	}
+}
+
+// SeedRoles creates the built-in roles that do not exist yet
+func SeedRoles(roles RoleStore) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, role := range BuiltInRoles() {
+		role.CreatedAt = now
+		role.UpdatedAt = now
+		err := roles.CreateRole(&ctx, *role)
+		if err != nil && err != ErrDuplicateRole {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolvePermissions returns the permissions granted by any of the roles, roles that no longer exist grant nothing
+func ResolvePermissions(roles RoleStore, names []UserRole) (Permissions, error) {
+	ctx := context.Background()
+
+	found, err := roles.GetRolesByName(&ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := Permissions{}
+	for _, role := range found {
+		granted := role.Permissions
+		if role.Name == Admin {
+			granted = AllPermissions
+		}
+		for _, permission := range granted {
+			if !permissions.Has(permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// UnknownRoles returns the names among the names that are not the name of any role
+func UnknownRoles(ctx context.Context, roles RoleStore, names []UserRole) ([]UserRole, error) {
+	found, err := roles.GetRolesByName(&ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	unknown := []UserRole{}
+	for _, name := range names {
+		exists := false
+		for _, role := range found {
+			if role.Name == name {
+				exists = true
+			}
+		}
+		if !exists {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown, nil
+}
+
+// CheckRoleAssignment returns ErrRoleNotFound when one of the roles does not exist and ErrRoleEscalation when one of
+// them grants a permission that the client does not have, so that no client can hand out more than it holds
+func CheckRoleAssignment(ctx context.Context, roles RoleStore, names []UserRole) error {
+	found, err := roles.GetRolesByName(&ctx, names)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var role *Role
+		for _, r := range found {
+			if r.Name == name {
+				role = r
+			}
+		}
+		if role == nil {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+		}
+
+		granted := role.Permissions
+		if role.Name == Admin {
+			granted = AllPermissions
+		}
+		err = checkGrantedPermissions(ctx, granted)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckUserManagement returns ErrRoleEscalation when the user holds a permission that the client does not have, so
+// that no client can take over or delete a user more privileged than itself
+func CheckUserManagement(ctx context.Context, roles RoleStore, user *User) error {
+	permissions, err := ResolvePermissions(roles, user.Roles)
+	if err != nil {
+		return err
+	}
+	return checkGrantedPermissions(ctx, permissions)
+}
+
+// checkGrantedPermissions returns ErrRoleEscalation when the client does not have one of the permissions
+func checkGrantedPermissions(ctx context.Context, permissions Permissions) error {
+	for _, permission := range permissions {
+		if !HasPermission(ctx, permission) {
+			return fmt.Errorf("%w: %s", ErrRoleEscalation, permission)
+		}
+	}
+	return nil
+}
+
+// CreateRole validates the role and creates it, the client can only grant the permissions it has
+func CreateRole(ctx context.Context, roles RoleStore, role Role) (*Role, error) {
+	err := checkGrantedPermissions(ctx, role.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	role.BuiltIn = false
+	role.CreatedAt = now
+	role.UpdatedAt = now
+	role.Permissions = role.Permissions.sorted()
+	err = roles.CreateRole(&ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// UpdateRole replaces the description and the permissions of the role, the client can only grant the permissions it
+// has and the Admin role can not be changed
+func UpdateRole(ctx context.Context, roles RoleStore, role Role) (*Role, error) {
+	existing, err := roles.GetRolesByName(&ctx, []UserRole{role.Name})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, ErrRoleNotFound
+	}
+	if role.Name == Admin {
+		return nil, ErrBuiltInRole
+	}
+
+	err = checkGrantedPermissions(ctx, role.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role.BuiltIn = existing[0].BuiltIn
+	role.CreatedAt = existing[0].CreatedAt
+	role.UpdatedAt = time.Now()
+	role.Permissions = role.Permissions.sorted()
+	err = roles.UpdateRole(&ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// DeleteRole deletes a role that is neither built-in nor held by any user, including soft deleted users
+func DeleteRole(ctx context.Context, roles RoleStore, users UserStore, name UserRole) error {
+	existing, err := roles.GetRolesByName(&ctx, []UserRole{name})
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return ErrRoleNotFound
+	}
+	if existing[0].BuiltIn {
+		return ErrBuiltInRole
+	}
+
+	// the users are listed without a client so that the visibility rules do not hide any of them
+	listCtx := context.Background()
+	page, err := users.GetUsers(&listCtx, &UserFilter{Roles: []UserRole{name}, IncludeDeleted: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(page.Users) > 0 {
+		return ErrRoleInUse
+	}
+
+	return roles.DeleteRole(&ctx, name)
+}
+
+// sorted returns the permissions without duplicates in the order of AllPermissions
+func (permissions Permissions) sorted() Permissions {
+	result := Permissions{}
+	for _, permission := range AllPermissions {
+		if permissions.Has(permission) {
+			result = append(result, permission)
+		}
+	}
+	return result
+}
+
+// roleNamePattern restricts role names to letters, digits, underscores and hyphens, starting with a letter
+var roleNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+func (role *Role) Validate() error {
+	validate := validator.New()
+	validate.RegisterValidation("role_name", validateRoleName)
+	validate.RegisterValidation("permissions", validatePermissions)
+
+	return validate.Struct(role)
+}
+
+func validateRoleName(fl validator.FieldLevel) bool {
+	return roleNamePattern.MatchString(fl.Field().String())
+}
+
+func validatePermissions(fl validator.FieldLevel) bool {
+	permissions, ok := fl.Field().Interface().(Permissions)
+	if !ok {
+		return false
+	}
+	for _, permission := range permissions {
+		if !AllPermissions.Has(permission) {
+			return false
+		}
+	}
+	return true
+}
+
+func (role *Role) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(role)
+}
+
+func (role *Role) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, role)
+}
+
+func (roles Roles) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, roles)
+}
+
+// sortRoles orders the roles by name the same way GetRoles of MongoRoleStore does
+func sortRoles(roles Roles) {
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryRoleStore is a RoleStore that keeps roles in memory, used for tests and local demos
+type MemoryRoleStore struct {
+	mu    sync.RWMutex
+	roles map[UserRole]Role
+}
+
+func NewMemoryRoleStore() *MemoryRoleStore {
+	return &MemoryRoleStore{
+		roles: map[UserRole]Role{},
+	}
+}
+
+func (s *MemoryRoleStore) GetRoles(ctx *context.Context) (Roles, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := Roles{}
+	for _, role := range s.roles {
+		role := role
+		roles = append(roles, &role)
+	}
+	sortRoles(roles)
+	return roles, nil
+}
+
+func (s *MemoryRoleStore) GetRolesByName(ctx *context.Context, names []UserRole) (Roles, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := Roles{}
+	for _, name := range names {
+		if role, ok := s.roles[name]; ok {
+			roles = append(roles, &role)
+		}
+	}
+	return roles, nil
+}
+
+func (s *MemoryRoleStore) CreateRole(ctx *context.Context, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[role.Name]; ok {
+		return ErrDuplicateRole
+	}
+	s.roles[role.Name] = role
+	return nil
+}
+
+func (s *MemoryRoleStore) UpdateRole(ctx *context.Context, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.roles[role.Name]
+	if !ok {
+		return ErrRoleNotFound
+	}
+	existing.Description = role.Description
+	existing.Permissions = role.Permissions
+	existing.UpdatedAt = role.UpdatedAt
+	s.roles[role.Name] = existing
+	return nil
+}
+
+func (s *MemoryRoleStore) DeleteRole(ctx *context.Context, name UserRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[name]; !ok {
+		return ErrRoleNotFound
+	}
+	delete(s.roles, name)
+	return nil
+}
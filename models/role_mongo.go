@@ -0,0 +1,98 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRoleStore is a RoleStore backed by the roles collection in MongoDB
+type MongoRoleStore struct{}
+
+func NewMongoRoleStore() *MongoRoleStore {
+	return &MongoRoleStore{}
+}
+
+func (s *MongoRoleStore) GetRoles(ctx *context.Context) (Roles, error) {
+	return s.findRoles(ctx, bson.M{})
+}
+
+func (s *MongoRoleStore) GetRolesByName(ctx *context.Context, names []UserRole) (Roles, error) {
+	return s.findRoles(ctx, bson.M{"_id": bson.M{"$in": names}})
+}
+
+// findRoles returns the roles matching the filter sorted by name
+func (s *MongoRoleStore) findRoles(ctx *context.Context, filter bson.M) (Roles, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := db.Collection("roles").Find(*ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := Roles{}
+	err = cur.All(*ctx, &roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (s *MongoRoleStore) CreateRole(ctx *context.Context, role Role) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("roles").InsertOne(*ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateRole
+	}
+	return err
+}
+
+func (s *MongoRoleStore) UpdateRole(ctx *context.Context, role Role) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": role.Name}
+	updater := bson.M{"$set": bson.M{
+		"description": role.Description,
+		"permissions": role.Permissions,
+		"updated_at":  role.UpdatedAt,
+	}}
+	result, err := db.Collection("roles").UpdateOne(*ctx, filter, updater)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func (s *MongoRoleStore) DeleteRole(ctx *context.Context, name UserRole) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Collection("roles").DeleteOne(*ctx, bson.M{"_id": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolvePermissions(t *testing.T) {
+	ctx := context.Background()
+	roles := NewMemoryRoleStore()
+	err := SeedRoles(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateRole(context.WithValue(ctx, "user_permissions", AllPermissions), roles, Role{
+		Name:        "Viewer",
+		Permissions: Permissions{PermissionUsersRead, PermissionSessionsRead, PermissionUsersRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err := ResolvePermissions(roles, []UserRole{Admin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != len(AllPermissions) {
+		t.Errorf("the Admin role does not grant every permission: %v", permissions)
+	}
+
+	// the permissions of several roles are merged and the roles that do not exist grant nothing
+	permissions, err = ResolvePermissions(roles, []UserRole{General, "Viewer", "Gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != 2 || !permissions.Has(PermissionUsersRead) || !permissions.Has(PermissionSessionsRead) {
+		t.Errorf("got permissions %v, want users:read and sessions:read", permissions)
+	}
+
+	unknown, err := UnknownRoles(ctx, roles, []UserRole{General, "Viewer", "Gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknown) != 1 || unknown[0] != "Gone" {
+		t.Errorf("got unknown roles %v, want Gone", unknown)
+	}
+}
+
+func TestCheckRoleAssignment(t *testing.T) {
+	roles := NewMemoryRoleStore()
+	err := SeedRoles(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), "user_permissions", Permissions{PermissionUsersRead, PermissionUsersWrite})
+
+	err = CheckRoleAssignment(ctx, roles, []UserRole{General})
+	if err != nil {
+		t.Errorf("assigning a role granting nothing: %v", err)
+	}
+	err = CheckRoleAssignment(ctx, roles, []UserRole{Admin})
+	if !errors.Is(err, ErrRoleEscalation) {
+		t.Errorf("assigning a role granting more than the client: got %v, want %v", err, ErrRoleEscalation)
+	}
+	err = CheckRoleAssignment(ctx, roles, []UserRole{"Gone"})
+	if !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("assigning a role that does not exist: got %v, want %v", err, ErrRoleNotFound)
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_permissions", AllPermissions)
+	roles := NewMemoryRoleStore()
+	users := NewMemoryUserStore()
+	err := SeedRoles(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateRole(ctx, roles, Role{Name: "Viewer", Permissions: Permissions{PermissionUsersRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := users.CreateUser(&ctx, User{Roles: []UserRole{"Viewer"}, FirstName: "Joshua", Username: "joshua", Password: "Zq8#pLm2vR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = users.DeleteUser(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DeleteRole(ctx, roles, users, General)
+	if err != ErrBuiltInRole {
+		t.Errorf("deleting a built-in role: got %v, want %v", err, ErrBuiltInRole)
+	}
+	// a soft deleted user may still be restored with the role
+	err = DeleteRole(ctx, roles, users, "Viewer")
+	if err != ErrRoleInUse {
+		t.Errorf("deleting a role held by a deleted user: got %v, want %v", err, ErrRoleInUse)
+	}
+
+	_, err = users.PurgeUsers(&ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = DeleteRole(ctx, roles, users, "Viewer")
+	if err != nil {
+		t.Errorf("deleting a role held by nobody: %v", err)
+	}
+	err = DeleteRole(ctx, roles, users, "Viewer")
+	if err != ErrRoleNotFound {
+		t.Errorf("deleting a role that does not exist: got %v, want %v", err, ErrRoleNotFound)
+	}
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The sessions of a user that are returned in the response
+// swagger:response sessionsResponse
+type sessionsResponseWrapper struct {
+	// in:body
+	Body Sessions
+}
+
+// swagger:parameters revokeSession
+type sessionIdParameterWrapper struct {
+	// The ID of the session to revoke
+	// in:query
+	// required:true
+	Id string `json:"id"`
+}
+
+// swagger:parameters getUserSessions revokeUserSessions
+type sessionUserIdParameterWrapper struct {
+	// The ID of the user whose sessions are managed
+	// in:query
+	// required:true
+	Id string `json:"id"`
+}
+
+// Session defines a login of a user, the refresh tokens rotated from the login form the family of the session
+// swagger:model
+type Session struct {
+	// the ID of the session, also the family of its refresh tokens
+	// required:true
+	Id string `bson:"_id"          json:"id"`
+	// the ID of the user that logged in
+	// required:true
+	UserId string `bson:"user_id"      json:"user_id"`
+	// the label of the device given on login
+	Device string `bson:"device"       json:"device"`
+	// the IP address the user logged in from
+	IP string `bson:"ip"           json:"ip"`
+	// the user agent the user logged in with
+	UserAgent string `bson:"user_agent"   json:"user_agent"`
+	// the date the user logged in at
+	// required:true
+	CreatedAt time.Time `bson:"created_at"   json:"created_at"`
+	// the date the tokens of the session were last refreshed at
+	// required:true
+	LastUsedAt time.Time `bson:"last_used_at" json:"last_used_at"`
+	// the date the session expires at unless its tokens are refreshed
+	// required:true
+	ExpiresAt time.Time `bson:"expires_at"   json:"expires_at"`
+	// whether the session is the one of the access token of the client
+	Current bool `bson:"-"            json:"current"`
+}
+
+type Sessions []*Session
+
+func (sessions Sessions) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, sessions)
+}
+
+func sessionRevocationId(sessionId string) string {
+	return "session:" + sessionId
+}
+
+// MaxSessionsPerUser reads the default maximum number of concurrent sessions of a user from MAX_SESSIONS_PER_USER,
+// 0 or an invalid value allows any number of sessions
+func MaxSessionsPerUser() int {
+	max, err := strconv.Atoi(os.Getenv("MAX_SESSIONS_PER_USER"))
+	if err != nil || max < 0 {
+		return 0
+	}
+	return max
+}
+
+// SessionLimit returns the maximum number of concurrent sessions of the user, the cap set on the user or
+// MaxSessionsPerUser when none is set, 0 allows any number of sessions
+func (user *User) SessionLimit() int {
+	if user.MaxSessions != nil {
+		return *user.MaxSessions
+	}
+	return MaxSessionsPerUser()
+}
+
+// StartSession records a new session of the user and issues its first pair of tokens. When the user has more
+// sessions than its SessionLimit allows, the least recently used ones are revoked.
+func StartSession(tokens TokenStore, user *User, session Session) (*UserToken, error) {
+	ctx := context.Background()
+	now := time.Now()
+
+	session.Id = primitive.NewObjectID().Hex()
+	session.UserId = user.Id.Hex()
+	session.CreatedAt = now
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(TokenTypeRefresh.Lifetime())
+	err := tokens.CreateSession(&ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	userTokens, err := IssueTokens(tokens, user, session.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	max := user.SessionLimit()
+	if max == 0 {
+		return userTokens, nil
+	}
+
+	sessions, err := tokens.GetUserSessions(&ctx, session.UserId)
+	if err != nil {
+		return nil, err
+	}
+	for i := max; i < len(sessions); i++ {
+		if sessions[i].Id == session.Id {
+			continue
+		}
+		err = RevokeSession(tokens, sessions[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return userTokens, nil
+}
+
+// RevokeSession ends the session, neither its access tokens nor its refresh tokens can be used again
+func RevokeSession(tokens TokenStore, id string) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	err := tokens.Revoke(&ctx, Revocation{
+		Id:        sessionRevocationId(id),
+		RevokedAt: now,
+		ExpiresAt: now.Add(TokenTypeAccess.Lifetime()),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tokens.RevokeTokenFamily(&ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return tokens.DeleteSession(&ctx, id)
+}
+
+// RevokeUserSessions ends every session of the user, the tokens issued so far can not be used again
+func RevokeUserSessions(tokens TokenStore, userId string) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	// the revocation of the user spares the tokens issued in its second, the ones of the sessions are exact
+	sessions, err := tokens.GetUserSessions(&ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		err = RevokeSession(tokens, session.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tokens.Revoke(&ctx, Revocation{
+		Id:        userRevocationId(userId),
+		RevokedAt: now,
+		ExpiresAt: now.Add(TokenTypeAccess.Lifetime()),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tokens.RevokeUserRefreshTokens(&ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return tokens.DeleteUserSessions(&ctx, userId)
+}
+
+// RevokeOtherSessions ends every session of the user except the given one, which stays usable
+func RevokeOtherSessions(tokens TokenStore, userId string, sessionId string) error {
+	ctx := context.Background()
+
+	sessions, err := tokens.GetUserSessions(&ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.Id == sessionId {
+			continue
+		}
+		err = RevokeSession(tokens, session.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshToken is the server side record of an issued refresh token. Every refresh token belongs to a family
+// started by a login, refreshing uses up the token and issues the next one of the same family.
+type RefreshToken struct {
+	// the JWT ID of the refresh token
+	Id string `bson:"_id"`
+	// the family of the refresh token, shared by every token rotated from the same login
+	Family string `bson:"family"`
+	// the ID of the user the refresh token was issued to
+	UserId string `bson:"user_id"`
+	// the date the refresh token was issued at
+	CreatedAt time.Time `bson:"created_at"`
+	// the date the refresh token expires at
+	ExpiresAt time.Time `bson:"expires_at"`
+	// the date the refresh token was exchanged for a new pair of tokens
+	UsedAt *time.Time `bson:"used_at"`
+	// the date the family of the refresh token was revoked at
+	RevokedAt *time.Time `bson:"revoked_at"`
+}
+
+// Revocation is an entry of the revocation list, either of the tokens of a session or of every token of a user
+// issued before it. Entries expire once the tokens they revoke would have expired anyway.
+type Revocation struct {
+	// "session:" followed by the session ID, or "user:" followed by the user ID
+	Id string `bson:"_id"`
+	// the date the tokens were revoked at
+	RevokedAt time.Time `bson:"revoked_at"`
+	// the date after which the entry is no longer needed
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// TokenStore defines the persistence operations on sessions and issued tokens used by the handlers
+type TokenStore interface {
+	CreateSession(ctx *context.Context, session Session) error
+	// GetSession returns the session unless it has ended, ErrSessionNotFound otherwise
+	GetSession(ctx *context.Context, id string) (*Session, error)
+	// GetUserSessions returns the sessions of the user that have not ended, the most recently used first
+	GetUserSessions(ctx *context.Context, userId string) (Sessions, error)
+	TouchSession(ctx *context.Context, id string, usedAt time.Time, expiresAt time.Time) error
+	DeleteSession(ctx *context.Context, id string) error
+	DeleteUserSessions(ctx *context.Context, userId string) error
+	CreateRefreshToken(ctx *context.Context, token RefreshToken) error
+	// UseRefreshToken marks the refresh token as used, returning ErrRefreshTokenReused when it was already used
+	// and ErrInvalidToken when it is unknown or revoked
+	UseRefreshToken(ctx *context.Context, id string) (*RefreshToken, error)
+	RevokeTokenFamily(ctx *context.Context, family string) error
+	RevokeUserRefreshTokens(ctx *context.Context, userId string) error
+	Revoke(ctx *context.Context, revocation Revocation) error
+	// GetRevocations returns the unexpired revocation entries among the given IDs
+	GetRevocations(ctx *context.Context, ids []string) ([]Revocation, error)
+}
+
+func userRevocationId(userId string) string {
+	return "user:" + userId
+}
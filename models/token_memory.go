@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryTokenStore is a TokenStore that keeps tokens in memory, used for tests and local demos
+type MemoryTokenStore struct {
+	mu            sync.Mutex
+	sessions      map[string]Session
+	refreshTokens map[string]RefreshToken
+	revocations   map[string]Revocation
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		sessions:      map[string]Session{},
+		refreshTokens: map[string]RefreshToken{},
+		revocations:   map[string]Revocation{},
+	}
+}
+
+func (s *MemoryTokenStore) CreateSession(ctx *context.Context, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.Id] = session
+	return nil
+}
+
+func (s *MemoryTokenStore) GetSession(ctx *context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (s *MemoryTokenStore) GetUserSessions(ctx *context.Context, userId string) (Sessions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sessions := Sessions{}
+	for id, session := range s.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(s.sessions, id)
+			continue
+		}
+		if session.UserId == userId {
+			session := session
+			sessions = append(sessions, &session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemoryTokenStore) TouchSession(ctx *context.Context, id string, usedAt time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	session.LastUsedAt = usedAt
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *MemoryTokenStore) DeleteSession(ctx *context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemoryTokenStore) DeleteUserSessions(ctx *context.Context, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserId == userId {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) CreateRefreshToken(ctx *context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[token.Id] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) UseRefreshToken(ctx *context.Context, id string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[id]
+	if !ok || token.RevokedAt != nil {
+		return nil, ErrInvalidToken
+	}
+	if token.UsedAt != nil {
+		return &token, ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+	s.refreshTokens[id] = token
+
+	return &token, nil
+}
+
+func (s *MemoryTokenStore) RevokeTokenFamily(ctx *context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.refreshTokens {
+		if token.Family == family && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeUserRefreshTokens(ctx *context.Context, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.refreshTokens {
+		if token.UserId == userId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) Revoke(ctx *context.Context, revocation Revocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revocations[revocation.Id] = revocation
+	return nil
+}
+
+func (s *MemoryTokenStore) GetRevocations(ctx *context.Context, ids []string) ([]Revocation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	revocations := []Revocation{}
+	for _, id := range ids {
+		revocation, ok := s.revocations[id]
+		if !ok {
+			continue
+		}
+		if !revocation.ExpiresAt.After(now) {
+			delete(s.revocations, id)
+			continue
+		}
+		revocations = append(revocations, revocation)
+	}
+	return revocations, nil
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoTokenStore is a TokenStore backed by the sessions, refresh_tokens and revocations collections in MongoDB
+type MongoTokenStore struct{}
+
+func NewMongoTokenStore() *MongoTokenStore {
+	return &MongoTokenStore{}
+}
+
+func (s *MongoTokenStore) CreateSession(ctx *context.Context, session Session) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sessions").InsertOne(*ctx, session)
+	return err
+}
+
+func (s *MongoTokenStore) GetSession(ctx *context.Context, id string) (*Session, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the TTL monitor only runs periodically so expired sessions are filtered out explicitly
+	session := Session{}
+	filter := bson.M{"_id": id, "expires_at": bson.M{"$gt": time.Now()}}
+	err = db.Collection("sessions").FindOne(*ctx, filter).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (s *MongoTokenStore) GetUserSessions(ctx *context.Context, userId string) (Sessions, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"user_id": userId, "expires_at": bson.M{"$gt": time.Now()}}
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cur, err := db.Collection("sessions").Find(*ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := Sessions{}
+	err = cur.All(*ctx, &sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *MongoTokenStore) TouchSession(ctx *context.Context, id string, usedAt time.Time, expiresAt time.Time) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": id}
+	updater := bson.M{"$set": bson.M{"last_used_at": usedAt, "expires_at": expiresAt}}
+
+	result, err := db.Collection("sessions").UpdateOne(*ctx, filter, updater)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) DeleteSession(ctx *context.Context, id string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sessions").DeleteOne(*ctx, bson.M{"_id": id})
+	return err
+}
+
+func (s *MongoTokenStore) DeleteUserSessions(ctx *context.Context, userId string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sessions").DeleteMany(*ctx, bson.M{"user_id": userId})
+	return err
+}
+
+func (s *MongoTokenStore) CreateRefreshToken(ctx *context.Context, token RefreshToken) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("refresh_tokens").InsertOne(*ctx, token)
+	return err
+}
+
+func (s *MongoTokenStore) UseRefreshToken(ctx *context.Context, id string) (*RefreshToken, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the token is only used when it was not used before, so that two concurrent refreshes can not both succeed
+	token := RefreshToken{}
+	filter := bson.M{"_id": id, "used_at": nil, "revoked_at": nil}
+	updater := bson.M{"$set": bson.M{"used_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = db.Collection("refresh_tokens").FindOneAndUpdate(*ctx, filter, updater, opts).Decode(&token)
+	if err == nil {
+		return &token, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	err = db.Collection("refresh_tokens").FindOne(*ctx, bson.M{"_id": id}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if token.RevokedAt != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &token, ErrRefreshTokenReused
+}
+
+func (s *MongoTokenStore) RevokeTokenFamily(ctx *context.Context, family string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"family": family, "revoked_at": nil}
+	updater := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	_, err = db.Collection("refresh_tokens").UpdateMany(*ctx, filter, updater)
+	return err
+}
+
+func (s *MongoTokenStore) RevokeUserRefreshTokens(ctx *context.Context, userId string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"user_id": userId, "revoked_at": nil}
+	updater := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	_, err = db.Collection("refresh_tokens").UpdateMany(*ctx, filter, updater)
+	return err
+}
+
+func (s *MongoTokenStore) Revoke(ctx *context.Context, revocation Revocation) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": revocation.Id}
+	opts := options.Replace().SetUpsert(true)
+
+	_, err = db.Collection("revocations").ReplaceOne(*ctx, filter, revocation, opts)
+	return err
+}
+
+func (s *MongoTokenStore) GetRevocations(ctx *context.Context, ids []string) ([]Revocation, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	// the TTL monitor only runs periodically so expired entries are filtered out explicitly
+	filter := bson.M{"_id": bson.M{"$in": ids}, "expires_at": bson.M{"$gt": time.Now()}}
+	cur, err := db.Collection("revocations").Find(*ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := []Revocation{}
+	err = cur.All(*ctx, &revocations)
+	if err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
@@ -1,32 +1,32 @@
 package models
 
 import (
-	"SejutaCita/common"
 	"context"
 	"encoding/json"
 	"io"
-	"strings"
+	"os"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/go-playground/validator"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// A user that is returned in the response
+// A user that is returned in the response, fields are omitted based on the access rights of the client
 // swagger:response userResponse
 type userResponseWrapper struct {
 	// in:body
-	Body User
+	Body UserAdminView
 }
 
-// Users that are returned in the response
+// A page of users that is returned in the response, the Link header holds the URL of the next page
 // swagger:response usersResponse
 type usersResponseWrapper struct {
+	// The URL of the next page, omitted on the last page
+	Link string
 	// in:body
-	Body []User
+	Body UserAdminViewsPage
 }
 
 // User ID (string) that is returned in the response
@@ -38,7 +38,7 @@ type userIdResponseWrapper struct {
 	}
 }
 
-// swagger:parameters getUserById updateUser deleteUser
+// swagger:parameters getUserById updateUser deleteUser restoreUser
 type userIdParameterWrapper struct {
 	// The ID of the user to perform the operation on
 	// in:query
@@ -48,15 +48,66 @@ type userIdParameterWrapper struct {
 
 // swagger:parameters getUsers
 type usersGetParameterWrapper struct {
-	// The filter based on user's role
+	// The search on the names and username of the user, every word must prefix one of them
 	// in:query
-	Role UserRole `json:"role"`
-	// The sorting based on category
+	Query string `json:"q"`
+	// Only users holding any of these roles, multiple roles are separated by commas
+	// in:query
+	// collectionFormat:csv
+	Role []UserRole `json:"role"`
+	// Only users created after this time (RFC 3339)
+	// in:query
+	// swagger:strfmt date-time
+	CreatedAfter string `json:"created_after"`
+	// Only users created before this time (RFC 3339)
+	// in:query
+	// swagger:strfmt date-time
+	CreatedBefore string `json:"created_before"`
+	// Only users last updated after this time (RFC 3339)
+	// in:query
+	// swagger:strfmt date-time
+	UpdatedAfter string `json:"updated_after"`
+	// Only users last updated before this time (RFC 3339)
+	// in:query
+	// swagger:strfmt date-time
+	UpdatedBefore string `json:"updated_before"`
+	// Only the user with this username
+	// in:query
+	Username string `json:"username"`
+	// Only users whose username starts with this prefix
+	// in:query
+	UsernamePrefix string `json:"username_prefix"`
+	// Only users with this last name, ignoring case
+	// in:query
+	LastName string `json:"last_name"`
+	// Only users whose last name starts with this prefix, ignoring case
+	// in:query
+	LastNamePrefix string `json:"last_name_prefix"`
+	// Only users with (true) or without (false) a middle name
+	// in:query
+	HasMiddleName bool `json:"has_middle_name"`
+	// Only users with (true) or without (false) a last name
+	// in:query
+	HasLastName bool `json:"has_last_name"`
+	// The sort keys separated by commas, each prefixed with - for descending order, e.g. last_name,-created_at.
+	// Takes precedence over category and order, ties are always broken on the ID.
+	// in:query
+	Sort string `json:"sort"`
+	// The sorting based on category, users are sorted by relevance when searching without a category
 	// in:query
 	Category UserSortCategory `json:"category"`
 	// The sorting based on order
 	// in:query
 	Order SortOrder `json:"order"`
+	// Whether soft deleted users are included
+	// in:query
+	IncludeDeleted bool `json:"include_deleted"`
+	// The maximum number of users returned, 50 by default and at most 200
+	// in:query
+	Limit int `json:"limit"`
+	// The opaque cursor returned as next_cursor by the previous page
+	// in:query
+	Cursor string `json:"cursor"`
 }
 
 // swagger:parameters createUser
@@ -75,8 +126,7 @@ type userUpdateParameterWrapper struct {
 	Body UserUpdate
 }
 
-// User defines the structure for an API User on GET methods
-// swagger:model
+// User defines the structure of a User as it is stored, it must never be written to a response as is
 type User struct {
 	// the ID of the user
 	// required:true
@@ -90,13 +140,9 @@ type User struct {
 	UpdatedAt time.Time `bson:"updated_at"    json:"updated_at"`
 	// the date the user was deleted at
 	DeletedAt *time.Time `bson:"deleted_at"    json:"deleted_at"`
-	// the token of the user
-	Token *string `bson:"token"         json:"token"`
-	// the refresh token of the user
-	RefreshToken *string `bson:"refresh_token" json:"refresh_token"`
-	// the role of the user
+	// the names of the roles of the user
 	// required:true
-	Role UserRole `bson:"role"          json:"role"         validate:"role"`
+	Roles []UserRole `bson:"roles"         json:"roles"        validate:"roles"`
 	// the first name of the user
 	// required:true
 	FirstName string `bson:"first_name"    json:"first_name"   validate:"first_name"`
@@ -110,14 +156,32 @@ type User struct {
 	// the password of the user
 	// required:true
 	Password string `bson:"password"      json:"password"     validate:"password"`
+	// the email address of the user
+	Email *string `bson:"email"         json:"email"`
+	// the phone number of the user in the E.164 format
+	Phone *string `bson:"phone"         json:"phone"`
+	// the preferences of the user
+	Preferences UserPreferences `bson:"preferences"   json:"preferences"`
+	// the attributes of the user matched by the access policies, e.g. organization
+	Attributes map[string]string `bson:"attributes"    json:"attributes"   validate:"attributes"`
+	// the maximum number of concurrent sessions of the user, 0 for no cap, MAX_SESSIONS_PER_USER when not set
+	MaxSessions *int `bson:"max_sessions"  json:"max_sessions" validate:"omitempty,min=0"`
+	// the hashes of the previous passwords of the user, the most recent first, capped at PasswordHistorySize
+	PasswordHistory []string `bson:"password_history" json:"-"`
+	// the normalized words of the names and username of the user, maintained by the store for searching
+	SearchTerms []string `bson:"search_terms"  json:"-"`
+	// the sorted names of the roles of the user joined by commas, maintained by the store for sorting on the roles
+	RoleKey string `bson:"role_key"      json:"-"`
+	// the relevance of the user to the search query, only set by GetUsers
+	Score int `bson:"score,omitempty" json:"-"`
 }
 
 // UserCreate defines the structure for an API User on POST methods
 // swagger:model
 type UserCreate struct {
-	// the role of the user
+	// the names of the roles of the user
 	// required:true
-	Role UserRole `bson:"role"          json:"role"         validate:"role"`
+	Roles []UserRole `bson:"roles"         json:"roles"        validate:"roles"`
 	// the first name of the user
 	// required:true
 	FirstName string `bson:"first_name"    json:"first_name"   validate:"first_name"`
@@ -131,13 +195,17 @@ type UserCreate struct {
 	// the password of the user
 	// required:true
 	Password string `bson:"password"      json:"password"     validate:"password"`
+	// the attributes of the user matched by the access policies, e.g. organization
+	Attributes map[string]string `bson:"attributes"    json:"attributes"   validate:"attributes"`
+	// the maximum number of concurrent sessions of the user, 0 for no cap, MAX_SESSIONS_PER_USER when omitted
+	MaxSessions *int `bson:"max_sessions"  json:"max_sessions" validate:"omitempty,min=0"`
 }
 
 // UserUpdate defines the structure for an API User on PUT methods
 // swagger:model
 type UserUpdate struct {
-	// the role of the user
-	Role UserRole `bson:"role"          json:"role"         validate:"role"`
+	// the names of the roles of the user, replacing the current ones
+	Roles []UserRole `bson:"roles"         json:"roles"        validate:"roles"`
 	// the first name of the user
 	FirstName string `bson:"first_name"    json:"first_name"   validate:"first_name"`
 	// the middle name of the user
@@ -146,16 +214,87 @@ type UserUpdate struct {
 	LastName *string `bson:"last_name"     json:"last_name"`
 	// the password of the user
 	Password string `bson:"password"      json:"password"     validate:"password"`
+	// the attributes of the user matched by the access policies, replacing the current ones
+	Attributes map[string]string `bson:"attributes"    json:"attributes"   validate:"attributes"`
+	// the maximum number of concurrent sessions of the user, 0 for no cap
+	MaxSessions *int `bson:"max_sessions"  json:"max_sessions" validate:"omitempty,min=0"`
 }
 
 type Users []*User
 
-// swagger:enum UserRole
+// UserPublicView defines the public profile of a User that is returned to other users
+// swagger:model
+type UserPublicView struct {
+	// the ID of the user
+	// required:true
+	// swagger:strfmt bsonobjectid
+	Id primitive.ObjectID `json:"id"`
+	// the first name of the user
+	// required:true
+	FirstName string `json:"first_name"`
+	// the middle name of the user
+	MiddleName *string `json:"middle_name"`
+	// the last name of the user
+	LastName *string `json:"last_name"`
+	// the username of the user
+	// required:true
+	Username string `json:"username"`
+}
+
+// UserSelfView defines the User that is returned to the user themselves
+// swagger:model
+type UserSelfView struct {
+	UserPublicView
+	// the date the user was created at
+	// required:true
+	CreatedAt time.Time `json:"created_at"`
+	// the date the user was last updated at
+	// required:true
+	UpdatedAt time.Time `json:"updated_at"`
+	// the names of the roles of the user
+	// required:true
+	Roles []UserRole `json:"roles"`
+	// the email address of the user
+	Email *string `json:"email"`
+	// the phone number of the user in the E.164 format
+	Phone *string `json:"phone"`
+	// the preferences of the user
+	// required:true
+	Preferences UserPreferences `json:"preferences"`
+	// the attributes of the user matched by the access policies
+	Attributes map[string]string `json:"attributes"`
+}
+
+// UserAdminView defines the User that is returned to Admins
+// swagger:model
+type UserAdminView struct {
+	UserSelfView
+	// the date the user was deleted at
+	DeletedAt *time.Time `json:"deleted_at"`
+	// the maximum number of concurrent sessions of the user, 0 for no cap, MAX_SESSIONS_PER_USER when null
+	MaxSessions *int `json:"max_sessions"`
+}
+
+type UserAdminViews []*UserAdminView
+
+// UserAdminViewsPage defines a page of Users that is returned to Admins
+// swagger:model
+type UserAdminViewsPage struct {
+	// the users in the page
+	// required:true
+	Users UserAdminViews `json:"users"`
+	// the cursor of the next page, null on the last page
+	NextCursor *string `json:"next_cursor"`
+}
+
+// UserRole is the name of a role
 type UserRole string
 
 const (
+	// General is the built-in role of the users managing only themselves
 	General UserRole = "General"
-	Admin   UserRole = "Admin"
+	// Admin is the built-in role granted every permission
+	Admin UserRole = "Admin"
 )
 
 // swagger:enum UserSortCategory
@@ -163,41 +302,124 @@ type UserSortCategory string
 
 const (
 	CreatedAt UserSortCategory = "created_at"
+	UpdatedAt UserSortCategory = "updated_at"
 	FirstName UserSortCategory = "first_name"
+	LastName  UserSortCategory = "last_name"
+	Username  UserSortCategory = "username"
+	RoleName  UserSortCategory = "role"
+	Relevance UserSortCategory = "relevance"
 )
 
+// UserSort is one key of the sort applied when listing users
 type UserSort struct {
 	Category UserSortCategory
 	Order    SortOrder
 }
 
 type UserFilter struct {
-	Role *UserRole
-	Sort *UserSort
+	Query          string
+	Roles          []UserRole
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	UpdatedAfter   *time.Time
+	UpdatedBefore  *time.Time
+	Username       *string
+	UsernamePrefix *string
+	LastName       *string
+	LastNamePrefix *string
+	HasMiddleName  *bool
+	HasLastName    *bool
+	Sort           []UserSort
+	IncludeDeleted bool
+	Limit          int
+	Cursor         string
+}
+
+// UserStore defines the persistence operations on users used by the handlers
+type UserStore interface {
+	GetUserById(ctx *context.Context, id string) (*User, error)
+	// GetUserByIdIncludingDeleted returns the user whether it is soft deleted or not, for the operations on deleted
+	// users
+	GetUserByIdIncludingDeleted(ctx *context.Context, id string) (*User, error)
+	GetUserByUsername(ctx *context.Context, username string) (*User, error)
+	GetUsers(ctx *context.Context, filter *UserFilter) (*UserPage, error)
+	CreateUser(ctx *context.Context, user User) (primitive.ObjectID, error)
+	// UpdateUser updates the given fields of the user, a new password pushes the previous hash to the password
+	// history
+	UpdateUser(ctx *context.Context, id string, user User) (bool, error)
+	DeleteUser(ctx *context.Context, id string) (bool, error)
+	RestoreUser(ctx *context.Context, id string) (bool, error)
+	PurgeUsers(ctx *context.Context, deletedBefore time.Time) (int64, error)
+	// UpdateProfile applies the changes of the user to their own profile and returns the updated user
+	UpdateProfile(ctx *context.Context, id string, profile UserProfileUpdate) (*User, error)
+	// UpdatePasswordHash replaces the stored hash of the password without changing the password, leaving
+	// updated_at untouched
+	UpdatePasswordHash(ctx *context.Context, id primitive.ObjectID, hash string) error
 }
 
 func (user *User) ValidateCreate() error {
 	validate := validator.New()
-	validate.RegisterValidation("role", validateRole)
+	validate.RegisterValidation("roles", validateRoles)
 	validate.RegisterValidation("first_name", validateFirstName)
 	validate.RegisterValidation("username", validateUsername)
 	validate.RegisterValidation("password", validatePassword)
+	validate.RegisterValidation("attributes", validateAttributes)
 
 	return validate.Struct(user)
 }
 
 func (user *User) ValidateUpdate() error {
 	validate := validator.New()
-	validate.RegisterValidation("role", validateRole)
+	validate.RegisterValidation("roles", validateOptionalRoles)
 	validate.RegisterValidation("first_name", EmptyValidate)
 	validate.RegisterValidation("username", EmptyValidate)
 	validate.RegisterValidation("password", EmptyValidate)
+	validate.RegisterValidation("attributes", validateAttributes)
 
 	return validate.Struct(user)
 }
 
-func validateRole(fl validator.FieldLevel) bool {
-	return fl.Field().String() == string(General) || fl.Field().String() == string(Admin)
+// validateRoles requires at least one role and no empty or repeated role names, whether the roles exist is checked
+// against the RoleStore by the handlers
+func validateRoles(fl validator.FieldLevel) bool {
+	roles, ok := fl.Field().Interface().([]UserRole)
+	if !ok || len(roles) == 0 {
+		return false
+	}
+
+	seen := map[UserRole]bool{}
+	for _, role := range roles {
+		if role == "" || seen[role] {
+			return false
+		}
+		seen[role] = true
+	}
+	return true
+}
+
+// validateOptionalRoles leaves the roles untouched when they are omitted
+func validateOptionalRoles(fl validator.FieldLevel) bool {
+	if fl.Field().IsNil() {
+		return true
+	}
+	return validateRoles(fl)
+}
+
+// attributeNamePattern restricts the names of the attributes of users to the ones the policies can refer to
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// validateAttributes requires attribute names made of lower case letters, digits and underscores and non-empty values
+func validateAttributes(fl validator.FieldLevel) bool {
+	attributes, ok := fl.Field().Interface().(map[string]string)
+	if !ok {
+		return false
+	}
+	for name, value := range attributes {
+		if !attributeNamePattern.MatchString(name) || value == "" {
+			return false
+		}
+	}
+	return true
 }
 
 func validateFirstName(fl validator.FieldLevel) bool {
@@ -212,193 +434,167 @@ func validatePassword(fl validator.FieldLevel) bool {
 	return fl.Field().String() != ""
 }
 
-func (user *User) FromJSON(r io.Reader) error {
+func (create *UserCreate) FromJSON(r io.Reader) error {
 	e := json.NewDecoder(r)
-	return e.Decode(user)
+	return e.Decode(create)
 }
 
-func (user *User) ToJSON(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(user)
+// User returns the user to create, the fields that are not part of UserCreate are left to the store
+func (create *UserCreate) User() User {
+	return User{
+		Roles:       create.Roles,
+		FirstName:   create.FirstName,
+		MiddleName:  create.MiddleName,
+		LastName:    create.LastName,
+		Username:    create.Username,
+		Password:    create.Password,
+		Attributes:  create.Attributes,
+		MaxSessions: create.MaxSessions,
+	}
 }
 
-func (users *Users) ToJSON(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(users)
+func (update *UserUpdate) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(update)
 }
 
-func GetUserById(ctx *context.Context, id string) (*User, error) {
-	db, err := common.GetDb()
-	if err != nil {
-		return nil, err
-	}
-	user := User{}
-	filter := bson.M{"_id": common.ObjectIDFromHex(id)}
-	err = db.Collection("users").FindOne(*ctx, filter).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
+// User returns the changes of the update as a user, the fields that are not part of UserUpdate are left untouched
+func (update *UserUpdate) User() User {
+	return User{
+		Roles:       update.Roles,
+		FirstName:   update.FirstName,
+		MiddleName:  update.MiddleName,
+		LastName:    update.LastName,
+		Password:    update.Password,
+		Attributes:  update.Attributes,
+		MaxSessions: update.MaxSessions,
 	}
-	return &user, nil
 }
 
-func GetUserByUsername(ctx *context.Context, username string) (*User, error) {
-	db, err := common.GetDb()
-	if err != nil {
-		return nil, err
+func (user *User) PublicView() *UserPublicView {
+	return &UserPublicView{
+		Id:         user.Id,
+		FirstName:  user.FirstName,
+		MiddleName: user.MiddleName,
+		LastName:   user.LastName,
+		Username:   user.Username,
 	}
-
-	user := User{}
-	filter := bson.M{"username": username}
-	err = db.Collection("users").FindOne(*ctx, filter).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
-	}
-
-	return &user, nil
 }
 
-func GetUsers(ctx *context.Context, filter *UserFilter) (Users, error) {
-	context := *ctx
-
-	db, err := common.GetDb()
-	if err != nil {
-		return nil, err
+func (user *User) SelfView() *UserSelfView {
+	return &UserSelfView{
+		UserPublicView: *user.PublicView(),
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+		Roles:          user.Roles,
+		Email:          user.Email,
+		Phone:          user.Phone,
+		Preferences:    user.Preferences,
+		Attributes:     user.Attributes,
 	}
+}
 
-	pipeline := []bson.M{}
-	users := Users{}
-
-	if filter != nil {
-		if filter.Role != nil {
-			pipeline = append(pipeline, bson.M{"$match": bson.M{"role": filter.Role}})
-		}
-		if filter.Sort != nil {
-			pipeline = append(pipeline, bson.M{"$sort": bson.M{string(filter.Sort.Category): int(filter.Sort.Order)}})
-		}
+func (user *User) AdminView() *UserAdminView {
+	return &UserAdminView{
+		UserSelfView: *user.SelfView(),
+		DeletedAt:    user.DeletedAt,
+		MaxSessions:  user.MaxSessions,
 	}
+}
 
-	cur, err := db.Collection("users").Aggregate(*ctx, pipeline, options.Aggregate().SetCollation(&options.Collation{Locale: "en"}))
-	if err != nil {
-		if err == mongo.ErrNilCursor {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
+// ViewFor returns the representation of the user that the client in the context is allowed to see
+func (user *User) ViewFor(ctx context.Context) Response {
+	if HasPermission(ctx, PermissionUsersRead) {
+		return user.AdminView()
 	}
-
-	for cur.Next(*ctx) {
-		user := User{}
-		err := cur.Decode(&user)
-		if err != nil {
-			return nil, err
-		}
-		if context.Value("user_role") != "Admin" {
-			if user.Id.Hex() != context.Value("user_id") {
-				continue
-			}
-		}
-		users = append(users, &user)
+	if ctx.Value("user_id") == user.Id.Hex() {
+		return user.SelfView()
 	}
-	cur.Close(*ctx)
-
-	return users, nil
+	return user.PublicView()
 }
 
-func CreateUser(ctx *context.Context, user User) (primitive.ObjectID, error) {
-	db, err := common.GetDb()
-	if err != nil {
-		return primitive.NilObjectID, err
+func (users Users) AdminViews() UserAdminViews {
+	views := UserAdminViews{}
+	for _, user := range users {
+		views = append(views, user.AdminView())
 	}
+	return views
+}
 
-	user.Username = strings.ToLower(user.Username)
-	existingUser, err := GetUserByUsername(ctx, user.Username)
-	if existingUser != nil {
-		return primitive.NilObjectID, ErrDuplicateUsername
-	}
-	if err != nil && err != ErrUserNotFound {
-		return primitive.NilObjectID, err
+func (page *UserPage) AdminViews() *UserAdminViewsPage {
+	views := &UserAdminViewsPage{Users: page.Users.AdminViews()}
+	if page.NextCursor != "" {
+		views.NextCursor = &page.NextCursor
 	}
+	return views
+}
 
-	now := time.Now()
-	user.Id = primitive.NewObjectID()
-	user.CreatedAt = now
-	user.UpdatedAt = now
-	user.Password = HashAndSalt(user.Password)
-	result, err := db.Collection("users").InsertOne(*ctx, user)
-	if err != nil {
-		return primitive.NilObjectID, err
-	}
+func (view *UserPublicView) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, view)
+}
 
-	return result.InsertedID.(primitive.ObjectID), nil
+func (view *UserSelfView) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, view)
 }
 
-func UpdateUser(ctx *context.Context, id string, user User) (bool, error) {
-	db, err := common.GetDb()
-	if err != nil {
-		return false, err
-	}
+func (view *UserAdminView) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, view)
+}
 
-	_, err = GetUserById(ctx, id)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return false, ErrUserNotFound
-		}
-		return false, err
-	}
+func (views UserAdminViews) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, views)
+}
 
-	filter := bson.M{"_id": common.ObjectIDFromHex(id)}
-	updates := bson.M{}
-	if user.Role != "" {
-		updates["role"] = user.Role
-	}
-	if user.FirstName != "" {
-		updates["first_name"] = user.FirstName
-	}
-	if user.MiddleName != nil {
-		updates["middle_name"] = user.MiddleName
-	}
-	if user.LastName != nil {
-		updates["last_name"] = user.LastName
-	}
-	if user.Password != "" {
-		user.Password = HashAndSalt(user.Password)
-		updates["password"] = user.Password
-	}
-	updater := bson.M{"$set": updates}
+func (views *UserAdminViewsPage) ToJSON(w io.Writer) error {
+	return EncodeResponse(w, views)
+}
 
-	_, err = db.Collection("users").UpdateOne(*ctx, filter, updater)
-	if err != nil {
-		return false, err
+// UserRetention returns how long soft deleted users are kept before they can be purged,
+// configured in days through the USER_RETENTION_DAYS environment variable
+func UserRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("USER_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		days = 30
 	}
-
-	return true, nil
+	return time.Duration(days) * 24 * time.Hour
 }
 
-func DeleteUser(ctx *context.Context, id string) (bool, error) {
-	db, err := common.GetDb()
-	if err != nil {
-		return false, err
-	}
+// SeedUsers creates the default Admin and General users in a store that has no users yet
+func SeedUsers(store UserStore) error {
+	ctx := context.WithValue(context.Background(), "user_permissions", AllPermissions)
 
-	_, err = GetUserById(ctx, id)
+	page, err := store.GetUsers(&ctx, &UserFilter{Limit: 1, IncludeDeleted: true})
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return false, ErrUserNotFound
-		}
-		return false, err
+		return err
+	}
+	if len(page.Users) > 0 {
+		return nil
 	}
 
-	filter := bson.M{"_id": common.ObjectIDFromHex(id)}
-
-	_, err = db.Collection("users").DeleteOne(*ctx, filter)
-	if err != nil {
-		return false, err
+	middleName := "Scarra"
+	lastName := "Lie"
+	seeds := []User{
+		{
+			Roles:      []UserRole{Admin},
+			FirstName:  "William",
+			MiddleName: &middleName,
+			LastName:   &lastName,
+			Username:   "admin",
+			Password:   "admin",
+		},
+		{
+			Roles:     []UserRole{General},
+			FirstName: "Joshua",
+			Username:  "general",
+			Password:  "general",
+		},
+	}
+	for _, user := range seeds {
+		_, err = store.CreateUser(&ctx, user)
+		if err != nil {
+			return err
+		}
 	}
 
-	return true, nil
+	return nil
 }
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultUsersLimit is the number of users returned in a page when the client does not specify a limit
+const DefaultUsersLimit = 50
+
+// MaxUsersLimit is the maximum number of users that can be returned in a page
+const MaxUsersLimit = 200
+
+// UserPage is a page of users along with the cursor that continues after its last user
+type UserPage struct {
+	Users      Users
+	NextCursor string
+}
+
+// userCursor is the position of the last user of a page, encoded as an opaque string for clients.
+// The sort it was created for is kept so that a cursor can not be reused with a different sort.
+type userCursor struct {
+	Sort   string    `json:"s"`
+	Values []*string `json:"v,omitempty"`
+	Id     string    `json:"id"`
+}
+
+// sortKey identifies the sort a cursor belongs to
+func sortKey(keys []UserSort) string {
+	parts := []string{}
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s:%d", key.Category, key.direction()))
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeUserCursor returns the cursor positioned after the user for the given sort
+func encodeUserCursor(user *User, keys []UserSort) string {
+	cursor := userCursor{
+		Sort: sortKey(keys),
+		Id:   user.Id.Hex(),
+	}
+	for _, key := range keys {
+		var encoded *string
+		switch value := key.Category.value(user).(type) {
+		case time.Time:
+			encoded = common.StringAddress(value.UTC().Format(time.RFC3339Nano))
+		case string:
+			encoded = common.StringAddress(value)
+		case int:
+			encoded = common.StringAddress(strconv.Itoa(value))
+		}
+		cursor.Values = append(cursor.Values, encoded)
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor returns the position in the sort that the cursor continues after
+func decodeUserCursor(encoded string, keys []UserSort) (*userPosition, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := userCursor{}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.Sort != sortKey(keys) || len(cursor.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	position := &userPosition{}
+	position.id, err = primitive.ObjectIDFromHex(cursor.Id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	for i, key := range keys {
+		encoded := cursor.Values[i]
+		if encoded == nil {
+			position.values = append(position.values, nil)
+			continue
+		}
+
+		var value interface{}
+		switch key.Category {
+		case CreatedAt, UpdatedAt:
+			value, err = time.Parse(time.RFC3339Nano, *encoded)
+		case Relevance:
+			value, err = strconv.Atoi(*encoded)
+		default:
+			value = *encoded
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		position.values = append(position.values, value)
+	}
+
+	return position, nil
+}
+
+// limit returns the page size requested by the filter bounded by MaxUsersLimit
+func (filter *UserFilter) limit() int {
+	if filter == nil || filter.Limit <= 0 {
+		return DefaultUsersLimit
+	}
+	if filter.Limit > MaxUsersLimit {
+		return MaxUsersLimit
+	}
+	return filter.Limit
+}
+
+// searchTokens returns the normalized words of the search query of the filter
+func (filter *UserFilter) searchTokens() []string {
+	if filter == nil {
+		return nil
+	}
+	return searchTokens(filter.Query)
+}
+
+// newUserPage trims the users fetched with one extra user to the limit, setting the next cursor when there are more
+func newUserPage(users Users, limit int, keys []UserSort) *UserPage {
+	page := &UserPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = encodeUserCursor(page.Users[limit-1], keys)
+	}
+	return page
+}
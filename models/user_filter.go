@@ -0,0 +1,143 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// matches reports whether the user satisfies the filter, mirroring the conditions of mongoMatch
+func (filter *UserFilter) matches(user *User) bool {
+	if filter == nil {
+		return user.DeletedAt == nil
+	}
+	if user.DeletedAt != nil && !filter.IncludeDeleted {
+		return false
+	}
+	if len(filter.Roles) > 0 && !containsAnyRole(filter.Roles, user.Roles) {
+		return false
+	}
+	if !inTimeRange(user.CreatedAt, filter.CreatedAfter, filter.CreatedBefore) {
+		return false
+	}
+	if !inTimeRange(user.UpdatedAt, filter.UpdatedAfter, filter.UpdatedBefore) {
+		return false
+	}
+	if filter.Username != nil && user.Username != strings.ToLower(*filter.Username) {
+		return false
+	}
+	if filter.UsernamePrefix != nil && !strings.HasPrefix(user.Username, strings.ToLower(*filter.UsernamePrefix)) {
+		return false
+	}
+	if filter.LastName != nil && !strings.EqualFold(stringValue(user.LastName), *filter.LastName) {
+		return false
+	}
+	if filter.LastNamePrefix != nil && !strings.HasPrefix(strings.ToLower(stringValue(user.LastName)), strings.ToLower(*filter.LastNamePrefix)) {
+		return false
+	}
+	if filter.HasMiddleName != nil && (stringValue(user.MiddleName) != "") != *filter.HasMiddleName {
+		return false
+	}
+	if filter.HasLastName != nil && (stringValue(user.LastName) != "") != *filter.HasLastName {
+		return false
+	}
+	if tokens := filter.searchTokens(); len(tokens) > 0 && !matchesSearch(user, tokens) {
+		return false
+	}
+	return true
+}
+
+// mongoMatch returns the conditions of the $match stage selecting the users that satisfy the filter
+func (filter *UserFilter) mongoMatch() bson.M {
+	if filter == nil {
+		return bson.M{"deleted_at": nil}
+	}
+
+	conditions := []bson.M{}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, bson.M{"deleted_at": nil})
+	}
+	if len(filter.Roles) > 0 {
+		conditions = append(conditions, bson.M{"roles": bson.M{"$in": filter.Roles}})
+	}
+	if timeRange := timeRangeMatch(filter.CreatedAfter, filter.CreatedBefore); timeRange != nil {
+		conditions = append(conditions, bson.M{"created_at": timeRange})
+	}
+	if timeRange := timeRangeMatch(filter.UpdatedAfter, filter.UpdatedBefore); timeRange != nil {
+		conditions = append(conditions, bson.M{"updated_at": timeRange})
+	}
+	if filter.Username != nil {
+		conditions = append(conditions, bson.M{"username": strings.ToLower(*filter.Username)})
+	}
+	if filter.UsernamePrefix != nil {
+		conditions = append(conditions, bson.M{"username": prefixRegex(strings.ToLower(*filter.UsernamePrefix), "")})
+	}
+	if filter.LastName != nil {
+		conditions = append(conditions, bson.M{"last_name": bson.M{"$regex": "^" + regexp.QuoteMeta(*filter.LastName) + "$", "$options": "i"}})
+	}
+	if filter.LastNamePrefix != nil {
+		conditions = append(conditions, bson.M{"last_name": prefixRegex(*filter.LastNamePrefix, "i")})
+	}
+	if filter.HasMiddleName != nil {
+		conditions = append(conditions, presenceMatch("middle_name", *filter.HasMiddleName))
+	}
+	if filter.HasLastName != nil {
+		conditions = append(conditions, presenceMatch("last_name", *filter.HasLastName))
+	}
+	conditions = append(conditions, searchMatch(filter.searchTokens())...)
+
+	if len(conditions) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": conditions}
+}
+
+// containsAnyRole reports whether the user holds any of the roles
+func containsAnyRole(roles []UserRole, userRoles []UserRole) bool {
+	for _, r := range roles {
+		for _, userRole := range userRoles {
+			if r == userRole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inTimeRange(t time.Time, after *time.Time, before *time.Time) bool {
+	if after != nil && !t.After(*after) {
+		return false
+	}
+	if before != nil && !t.Before(*before) {
+		return false
+	}
+	return true
+}
+
+func timeRangeMatch(after *time.Time, before *time.Time) bson.M {
+	if after == nil && before == nil {
+		return nil
+	}
+	timeRange := bson.M{}
+	if after != nil {
+		timeRange["$gt"] = *after
+	}
+	if before != nil {
+		timeRange["$lt"] = *before
+	}
+	return timeRange
+}
+
+func prefixRegex(prefix string, options string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": options}
+}
+
+// presenceMatch matches the users where the optional field is set to a non empty value, or the opposite
+func presenceMatch(field string, present bool) bson.M {
+	if present {
+		return bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}
+	}
+	return bson.M{field: bson.M{"$in": bson.A{nil, ""}}}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserFilterMatches(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	dayAgo := now.Add(-24 * time.Hour)
+	middleName := "Scarra"
+	lastName := "Lie"
+	empty := ""
+
+	user := &User{
+		CreatedAt:  dayAgo,
+		UpdatedAt:  hourAgo,
+		Roles:      []UserRole{Admin},
+		FirstName:  "William",
+		MiddleName: &middleName,
+		LastName:   &lastName,
+		Username:   "william.lie",
+	}
+	user.SearchTerms = UserSearchTerms(user)
+	deleted := *user
+	deleted.DeletedAt = &now
+	withoutLastName := *user
+	withoutLastName.LastName = &empty
+
+	stringPtr := func(s string) *string { return &s }
+	boolPtr := func(b bool) *bool { return &b }
+	timePtr := func(t time.Time) *time.Time { return &t }
+
+	filters := []struct {
+		name    string
+		filter  *UserFilter
+		user    *User
+		matches bool
+	}{
+		{"no filter", nil, user, true},
+		{"no filter on a deleted user", nil, &deleted, false},
+		{"a deleted user", &UserFilter{}, &deleted, false},
+		{"including deleted users", &UserFilter{IncludeDeleted: true}, &deleted, true},
+		{"one of the roles", &UserFilter{Roles: []UserRole{General, Admin}}, user, true},
+		{"another role", &UserFilter{Roles: []UserRole{General}}, user, false},
+		{"created in the range", &UserFilter{CreatedAfter: timePtr(dayAgo.Add(-time.Minute)), CreatedBefore: &hourAgo}, user, true},
+		{"created at the exclusive bound", &UserFilter{CreatedAfter: &dayAgo}, user, false},
+		{"updated before the range", &UserFilter{UpdatedAfter: timePtr(hourAgo.Add(time.Minute))}, user, false},
+		{"updated in the range", &UserFilter{UpdatedBefore: &now}, user, true},
+		{"the username in any case", &UserFilter{Username: stringPtr("William.Lie")}, user, true},
+		{"part of the username", &UserFilter{Username: stringPtr("william")}, user, false},
+		{"a prefix of the username", &UserFilter{UsernamePrefix: stringPtr("WILL")}, user, true},
+		{"not a prefix of the username", &UserFilter{UsernamePrefix: stringPtr("lie")}, user, false},
+		{"the last name in any case", &UserFilter{LastName: stringPtr("lie")}, user, true},
+		{"a prefix of the last name", &UserFilter{LastNamePrefix: stringPtr("l")}, user, true},
+		{"another last name", &UserFilter{LastName: stringPtr("Li")}, user, false},
+		{"with a middle name", &UserFilter{HasMiddleName: boolPtr(true)}, user, true},
+		{"without a middle name", &UserFilter{HasMiddleName: boolPtr(false)}, user, false},
+		{"an empty last name is absent", &UserFilter{HasLastName: boolPtr(false)}, &withoutLastName, true},
+		{"an empty last name is not present", &UserFilter{HasLastName: boolPtr(true)}, &withoutLastName, false},
+		{"every condition", &UserFilter{Roles: []UserRole{Admin}, UsernamePrefix: stringPtr("will"), HasLastName: boolPtr(true), Query: "scar"}, user, true},
+		{"one failing condition", &UserFilter{Roles: []UserRole{Admin}, UsernamePrefix: stringPtr("will"), HasLastName: boolPtr(false)}, user, false},
+	}
+	for _, f := range filters {
+		if f.filter.matches(f.user) != f.matches {
+			t.Errorf("%s: got matches %t, want %t", f.name, !f.matches, f.matches)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUserStore is a UserStore that keeps users in memory, used for tests and local demos
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users: map[primitive.ObjectID]User{},
+	}
+}
+
+func (s *MemoryUserStore) GetUserById(ctx *context.Context, id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[common.ObjectIDFromHex(id)]
+	if !ok || user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserStore) GetUserByIdIncludingDeleted(ctx *context.Context, id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[common.ObjectIDFromHex(id)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserStore) GetUserByUsername(ctx *context.Context, username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, err := s.findByUsername(username)
+	if err != nil || user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryUserStore) GetUsers(ctx *context.Context, filter *UserFilter) (*UserPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userSort := filter.sort()
+	limit := filter.limit()
+	tokens := filter.searchTokens()
+
+	var pivot *userPosition
+	if filter != nil && filter.Cursor != "" {
+		var err error
+		pivot, err = decodeUserCursor(filter.Cursor, userSort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	users := Users{}
+	for _, user := range s.users {
+		user := user
+		if !filter.matches(&user) {
+			continue
+		}
+		if len(tokens) > 0 {
+			user.Score = searchScore(&user, tokens)
+		}
+		if pivot != nil && comparePositions(positionOf(&user, userSort), *pivot, userSort) <= 0 {
+			continue
+		}
+		if !isVisible(*ctx, &user) {
+			continue
+		}
+		users = append(users, cloneUser(user))
+	}
+
+	sortUsers(users, userSort)
+	if len(users) > limit+1 {
+		users = users[:limit+1]
+	}
+
+	return newUserPage(users, limit, userSort), nil
+}
+
+func (s *MemoryUserStore) CreateUser(ctx *context.Context, user User) (primitive.ObjectID, error) {
+	// the password is hashed before locking since hashing is deliberately slow
+	password, err := HashPassword(user.Password)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// usernames of soft deleted users stay reserved until they are purged so that they can be restored
+	user.Username = strings.ToLower(user.Username)
+	if _, err := s.findByUsername(user.Username); err == nil {
+		return primitive.NilObjectID, ErrDuplicateUsername
+	}
+
+	now := time.Now()
+	user.Id = primitive.NewObjectID()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Password = password
+	user.SearchTerms = UserSearchTerms(&user)
+	user.RoleKey = UserRoleKey(user.Roles)
+	user.Score = 0
+	s.users[user.Id] = *cloneUser(user)
+
+	return user.Id, nil
+}
+
+func (s *MemoryUserStore) UpdateUser(ctx *context.Context, id string, user User) (bool, error) {
+	// the password is hashed before locking since hashing is deliberately slow
+	if user.Password != "" {
+		password, err := HashPassword(user.Password)
+		if err != nil {
+			return false, err
+		}
+		user.Password = password
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existingUser, ok := s.users[common.ObjectIDFromHex(id)]
+	if !ok || existingUser.DeletedAt != nil {
+		return false, ErrUserNotFound
+	}
+
+	existingUser.UpdatedAt = time.Now()
+	if user.Roles != nil {
+		existingUser.Roles = user.Roles
+		existingUser.RoleKey = UserRoleKey(user.Roles)
+	}
+	if user.FirstName != "" {
+		existingUser.FirstName = user.FirstName
+	}
+	if user.MiddleName != nil {
+		existingUser.MiddleName = user.MiddleName
+	}
+	if user.LastName != nil {
+		existingUser.LastName = user.LastName
+	}
+	if user.Attributes != nil {
+		existingUser.Attributes = user.Attributes
+	}
+	if user.MaxSessions != nil {
+		existingUser.MaxSessions = user.MaxSessions
+	}
+	if user.Password != "" {
+		existingUser.PasswordHistory = pushPasswordHistory(existingUser.PasswordHistory, existingUser.Password)
+		existingUser.Password = user.Password
+	}
+	existingUser.SearchTerms = UserSearchTerms(&existingUser)
+	s.users[existingUser.Id] = *cloneUser(existingUser)
+
+	return true, nil
+}
+
+func (s *MemoryUserStore) UpdateProfile(ctx *context.Context, id string, profile UserProfileUpdate) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[common.ObjectIDFromHex(id)]
+	if !ok || user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+
+	profile.applyTo(&user)
+	user.UpdatedAt = time.Now()
+	s.users[user.Id] = *cloneUser(user)
+
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserStore) UpdatePasswordHash(ctx *context.Context, id primitive.ObjectID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Password = hash
+	s.users[id] = user
+
+	return nil
+}
+
+func (s *MemoryUserStore) DeleteUser(ctx *context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[common.ObjectIDFromHex(id)]
+	if !ok || user.DeletedAt != nil {
+		return false, ErrUserNotFound
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	s.users[user.Id] = user
+
+	return true, nil
+}
+
+func (s *MemoryUserStore) RestoreUser(ctx *context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[common.ObjectIDFromHex(id)]
+	if !ok {
+		return false, ErrUserNotFound
+	}
+	if user.DeletedAt == nil {
+		return false, ErrUserNotDeleted
+	}
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	s.users[user.Id] = user
+
+	return true, nil
+}
+
+func (s *MemoryUserStore) PurgeUsers(ctx *context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for id, user := range s.users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(deletedBefore) {
+			delete(s.users, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// findByUsername includes soft deleted users and must be called with the lock held
+func (s *MemoryUserStore) findByUsername(username string) (*User, error) {
+	username = strings.ToLower(username)
+	for _, user := range s.users {
+		if user.Username == username {
+			return cloneUser(user), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// cloneUser copies the slices, the map and the pointers of the user, so that the users kept by the store share no
+// memory with those handed to or returned to callers, which may modify them
+func cloneUser(user User) *User {
+	cloneString := func(value *string) *string {
+		if value == nil {
+			return nil
+		}
+		clone := *value
+		return &clone
+	}
+
+	if user.DeletedAt != nil {
+		deletedAt := *user.DeletedAt
+		user.DeletedAt = &deletedAt
+	}
+	if user.MaxSessions != nil {
+		maxSessions := *user.MaxSessions
+		user.MaxSessions = &maxSessions
+	}
+	user.MiddleName = cloneString(user.MiddleName)
+	user.LastName = cloneString(user.LastName)
+	user.Email = cloneString(user.Email)
+	user.Phone = cloneString(user.Phone)
+	if user.Roles != nil {
+		user.Roles = append([]UserRole{}, user.Roles...)
+	}
+	if user.Attributes != nil {
+		attributes := make(map[string]string, len(user.Attributes))
+		for name, value := range user.Attributes {
+			attributes[name] = value
+		}
+		user.Attributes = attributes
+	}
+	if user.PasswordHistory != nil {
+		user.PasswordHistory = append([]string{}, user.PasswordHistory...)
+	}
+	if user.SearchTerms != nil {
+		user.SearchTerms = append([]string{}, user.SearchTerms...)
+	}
+	return &user
+}
+
+// sortUsers orders the users the same way GetUsers of MongoUserStore does
+func sortUsers(users Users, keys []UserSort) {
+	sort.Slice(users, func(i, j int) bool {
+		return comparePositions(positionOf(users[i], keys), positionOf(users[j], keys), keys) < 0
+	})
+}
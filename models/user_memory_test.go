@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryUserStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+
+	id, err := store.CreateUser(&ctx, User{Roles: []UserRole{General}, FirstName: "Joshua", Username: "Joshua", Password: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.CreateUser(&ctx, User{Roles: []UserRole{General}, FirstName: "Joshua", Username: "joshua", Password: "general"})
+	if err != ErrDuplicateUsername {
+		t.Errorf("creating a user with a taken username: got error %v, want %v", err, ErrDuplicateUsername)
+	}
+
+	user, err := store.GetUserByUsername(&ctx, "JOSHUA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != id || user.Username != "joshua" || !VerifyPassword("general", user.Password) {
+		t.Errorf("the user was not stored with a lowercase username and a hashed password: %+v", user)
+	}
+
+	_, err = store.UpdateUser(&ctx, id.Hex(), User{FirstName: "Josh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.FirstName != "Josh" || len(user.Roles) != 1 || user.Roles[0] != General {
+		t.Errorf("the update did not change the given fields only: %+v", user)
+	}
+
+	_, err = store.DeleteUser(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.GetUserById(&ctx, id.Hex())
+	if err != ErrUserNotFound {
+		t.Errorf("getting a deleted user: got error %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestMemoryUserStoreSharesNoMemory(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+
+	lastName := "Lie"
+	user := User{Roles: []UserRole{General}, FirstName: "Joshua", LastName: &lastName, Username: "joshua", Password: "general"}
+	id, err := store.CreateUser(&ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastName = "Mallory"
+
+	stored, err := store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.LastName != "Lie" {
+		t.Fatalf("the created user changed with the user given: %s", *stored.LastName)
+	}
+
+	*stored.LastName = "Mallory"
+	stored.SearchTerms[0] = "mallory"
+	stored, err = store.GetUserByUsername(&ctx, "joshua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.LastName != "Lie" || stored.SearchTerms[0] != "joshua" {
+		t.Errorf("the stored user changed with the user returned: %s %v", *stored.LastName, stored.SearchTerms)
+	}
+
+	middleName := "Scarra"
+	_, err = store.UpdateUser(&ctx, id.Hex(), User{MiddleName: &middleName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	middleName = "Mallory"
+	stored, err = store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.MiddleName != "Scarra" {
+		t.Errorf("the updated user changed with the update given: %s", *stored.MiddleName)
+	}
+
+	phone := "+6281234567890"
+	updated, err := store.UpdateProfile(&ctx, id.Hex(), UserProfileUpdate{Phone: &phone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	*updated.Phone = "+6289999999999"
+	stored, err = store.GetUserById(&ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.Phone != "+6281234567890" {
+		t.Errorf("the stored user changed with the updated profile returned: %s", *stored.Phone)
+	}
+}
@@ -0,0 +1,383 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoUserStore is a UserStore backed by the users collection in MongoDB
+type MongoUserStore struct{}
+
+func NewMongoUserStore() *MongoUserStore {
+	return &MongoUserStore{}
+}
+
+func (s *MongoUserStore) GetUserById(ctx *context.Context, id string) (*User, error) {
+	return s.findUser(ctx, bson.M{"_id": common.ObjectIDFromHex(id), "deleted_at": nil})
+}
+
+func (s *MongoUserStore) GetUserByIdIncludingDeleted(ctx *context.Context, id string) (*User, error) {
+	return s.findUser(ctx, bson.M{"_id": common.ObjectIDFromHex(id)})
+}
+
+func (s *MongoUserStore) GetUserByUsername(ctx *context.Context, username string) (*User, error) {
+	return s.findUser(ctx, bson.M{"username": strings.ToLower(username), "deleted_at": nil})
+}
+
+// findUser returns the first user matching the filter, including soft deleted users unless the filter excludes them
+func (s *MongoUserStore) findUser(ctx *context.Context, filter bson.M) (*User, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	user := User{}
+	err = db.Collection("users").FindOne(*ctx, filter).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *MongoUserStore) GetUsers(ctx *context.Context, filter *UserFilter) (*UserPage, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	users := Users{}
+	userSort := filter.sort()
+	limit := filter.limit()
+	tokens := filter.searchTokens()
+
+	// the visibility rules of the client are part of the first stage so that they can use the indexes
+	match := bson.M{"$and": append([]bson.M{filter.mongoMatch()}, visibilityMatch(*ctx)...)}
+	pipeline := []bson.M{{"$match": match}}
+	if len(tokens) > 0 {
+		pipeline = append(pipeline, bson.M{"$addFields": bson.M{"score": searchScoreExpression(tokens)}})
+	}
+
+	// the cursor is matched after the score is computed since relevance sorting pages on the score
+	if filter != nil && filter.Cursor != "" {
+		pivot, err := decodeUserCursor(filter.Cursor, userSort)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"$or": afterPositionMatch(pivot, userSort)}})
+	}
+
+	// ties on the sort keys are broken on the ID so that pages never overlap or skip users
+	sortStage := bson.D{}
+	collated := false
+	for _, key := range userSort {
+		sortStage = append(sortStage, bson.E{Key: key.Category.field(), Value: key.direction()})
+		collated = collated || key.Category.collated()
+	}
+	sortStage = append(sortStage, bson.E{Key: "_id", Value: 1})
+	pipeline = append(pipeline, bson.M{"$sort": sortStage}, bson.M{"$limit": limit + 1})
+
+	// the collation is only needed to sort on text, prefix searches can not use the search_terms index with it
+	opts := options.Aggregate()
+	if collated {
+		opts.SetCollation(&options.Collation{Locale: "en"})
+	}
+
+	cur, err := db.Collection("users").Aggregate(*ctx, pipeline, opts)
+	if err != nil {
+		if err == mongo.ErrNilCursor {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	defer cur.Close(*ctx)
+
+	for cur.Next(*ctx) {
+		user := User{}
+		err := cur.Decode(&user)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return newUserPage(users, limit, userSort), nil
+}
+
+// afterPositionMatch returns the conditions matching the users that come after the position in the sort,
+// a user comes after it when it is equal on the first keys and after it on the next one, or equal on all keys
+// with a greater ID
+func afterPositionMatch(pivot *userPosition, keys []UserSort) []bson.M {
+	conditions := []bson.M{}
+	equal := []bson.M{}
+	for i, key := range keys {
+		field := key.Category.field()
+		if after := afterValueMatch(field, pivot.values[i], key.direction()); after != nil {
+			conditions = append(conditions, bson.M{"$and": append(append([]bson.M{}, equal...), after)})
+		}
+		equal = append(equal, bson.M{field: pivot.values[i]})
+	}
+	conditions = append(conditions, bson.M{"$and": append(equal, bson.M{"_id": bson.M{"$gt": pivot.id}})})
+	return conditions
+}
+
+// afterValueMatch returns the condition matching the values that come strictly after the value in the direction,
+// missing values sort first so nothing comes after them in descending order
+func afterValueMatch(field string, value interface{}, direction int) bson.M {
+	if direction > 0 {
+		if value == nil {
+			return bson.M{field: bson.M{"$ne": nil}}
+		}
+		return bson.M{field: bson.M{"$gt": value}}
+	}
+	if value == nil {
+		return nil
+	}
+	return bson.M{"$or": bson.A{bson.M{field: bson.M{"$lt": value}}, bson.M{field: nil}}}
+}
+
+func (s *MongoUserStore) CreateUser(ctx *context.Context, user User) (primitive.ObjectID, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	// usernames of soft deleted users stay reserved until they are purged so that they can be restored
+	user.Username = strings.ToLower(user.Username)
+	existingUser, err := s.findUser(ctx, bson.M{"username": user.Username})
+	if existingUser != nil {
+		return primitive.NilObjectID, ErrDuplicateUsername
+	}
+	if err != nil && err != ErrUserNotFound {
+		return primitive.NilObjectID, err
+	}
+
+	now := time.Now()
+	user.Id = primitive.NewObjectID()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Password, err = HashPassword(user.Password)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	user.SearchTerms = UserSearchTerms(&user)
+	user.RoleKey = UserRoleKey(user.Roles)
+	user.Score = 0
+	result, err := db.Collection("users").InsertOne(*ctx, user)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (s *MongoUserStore) UpdateUser(ctx *context.Context, id string, user User) (bool, error) {
+	_, err := s.updateUser(ctx, id, func(existingUser *User, updatedAt time.Time) (bson.M, error) {
+		updates := bson.M{"updated_at": updatedAt}
+		if user.Roles != nil {
+			updates["roles"] = user.Roles
+			updates["role_key"] = UserRoleKey(user.Roles)
+		}
+		if user.FirstName != "" {
+			updates["first_name"] = user.FirstName
+			existingUser.FirstName = user.FirstName
+		}
+		if user.MiddleName != nil {
+			updates["middle_name"] = user.MiddleName
+			existingUser.MiddleName = user.MiddleName
+		}
+		if user.LastName != nil {
+			updates["last_name"] = user.LastName
+			existingUser.LastName = user.LastName
+		}
+		if user.Attributes != nil {
+			updates["attributes"] = user.Attributes
+		}
+		if user.MaxSessions != nil {
+			updates["max_sessions"] = user.MaxSessions
+		}
+		updates["search_terms"] = UserSearchTerms(existingUser)
+		updater := bson.M{}
+		if user.Password != "" {
+			hash, err := HashPassword(user.Password)
+			if err != nil {
+				return nil, err
+			}
+			updates["password"] = hash
+			if size := PasswordHistorySize(); size > 0 {
+				updater["$push"] = bson.M{"password_history": bson.M{
+					"$each":     bson.A{existingUser.Password},
+					"$position": 0,
+					"$slice":    size,
+				}}
+			}
+		}
+		updater["$set"] = updates
+		return updater, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *MongoUserStore) UpdateProfile(ctx *context.Context, id string, profile UserProfileUpdate) (*User, error) {
+	return s.updateUser(ctx, id, func(user *User, updatedAt time.Time) (bson.M, error) {
+		profile.applyTo(user)
+		user.UpdatedAt = updatedAt
+
+		updates := bson.M{"search_terms": user.SearchTerms, "updated_at": updatedAt}
+		if profile.FirstName != nil {
+			updates["first_name"] = user.FirstName
+		}
+		if profile.MiddleName != nil {
+			updates["middle_name"] = user.MiddleName
+		}
+		if profile.LastName != nil {
+			updates["last_name"] = user.LastName
+		}
+		if profile.Email != nil {
+			updates["email"] = user.Email
+		}
+		if profile.Phone != nil {
+			updates["phone"] = user.Phone
+		}
+		if profile.Preferences != nil {
+			updates["preferences"] = user.Preferences
+		}
+		return bson.M{"$set": updates}, nil
+	})
+}
+
+// maxUserUpdateAttempts is the number of times an update is built again from the stored user before giving up
+const maxUserUpdateAttempts = 3
+
+// updateUser applies the update built from the stored user only while the user has neither been updated nor deleted
+// since it was read, so that the search terms and password history derived from the stored fields stay consistent
+// with them. The update is built again from the user read anew when another update came first.
+func (s *MongoUserStore) updateUser(ctx *context.Context, id string, build func(user *User, updatedAt time.Time) (bson.M, error)) (*User, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxUserUpdateAttempts; attempt++ {
+		user, err := s.GetUserById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		// dates are stored to the millisecond, the new updated_at must differ from the one guarded against
+		updatedAt := time.Now().Truncate(time.Millisecond)
+		if !updatedAt.After(user.UpdatedAt) {
+			updatedAt = user.UpdatedAt.Add(time.Millisecond)
+		}
+		updater, err := build(user, updatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{"_id": user.Id, "deleted_at": nil, "updated_at": user.UpdatedAt}
+		result, err := db.Collection("users").UpdateOne(*ctx, filter, updater)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount > 0 {
+			return user, nil
+		}
+	}
+
+	return nil, ErrUserUpdateConflict
+}
+
+func (s *MongoUserStore) UpdatePasswordHash(ctx *context.Context, id primitive.ObjectID, hash string) error {
+	db, err := common.GetDb()
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": id}
+	updater := bson.M{"$set": bson.M{"password": hash}}
+
+	_, err = db.Collection("users").UpdateOne(*ctx, filter, updater)
+	return err
+}
+
+func (s *MongoUserStore) DeleteUser(ctx *context.Context, id string) (bool, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.GetUserById(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": common.ObjectIDFromHex(id)}
+	updater := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+
+	_, err = db.Collection("users").UpdateOne(*ctx, filter, updater)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *MongoUserStore) RestoreUser(ctx *context.Context, id string) (bool, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return false, err
+	}
+
+	user, err := s.GetUserByIdIncludingDeleted(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if user.DeletedAt == nil {
+		return false, ErrUserNotDeleted
+	}
+
+	filter := bson.M{"_id": user.Id}
+	updater := bson.M{
+		"$set": bson.M{
+			"deleted_at": nil,
+			"updated_at": time.Now(),
+		},
+	}
+
+	_, err = db.Collection("users").UpdateOne(*ctx, filter, updater)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *MongoUserStore) PurgeUsers(ctx *context.Context, deletedBefore time.Time) (int64, error) {
+	db, err := common.GetDb()
+	if err != nil {
+		return 0, err
+	}
+
+	filter := bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": deletedBefore}}
+
+	result, err := db.Collection("users").DeleteMany(*ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
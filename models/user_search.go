@@ -0,0 +1,125 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeSearchText lowercases the text and strips its diacritics so that "José" matches "jose"
+func normalizeSearchText(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	normalized, _, err := transform.String(t, text)
+	if err != nil {
+		normalized = text
+	}
+	return strings.ToLower(normalized)
+}
+
+// splitSearchText splits normalized text into words on anything that is not a letter or a digit
+func splitSearchText(text string) []string {
+	return strings.FieldsFunc(normalizeSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// UserSearchTerms returns the normalized words of the names and username of the user that the q filter
+// of GetUsers matches against, the whole username is included so that "john.d" matches "john.doe"
+func UserSearchTerms(user *User) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	add := func(term string) {
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	add(normalizeSearchText(user.Username))
+	for _, field := range []string{user.Username, user.FirstName, stringValue(user.MiddleName), stringValue(user.LastName)} {
+		for _, term := range splitSearchText(field) {
+			add(term)
+		}
+	}
+
+	return terms
+}
+
+// searchTokens returns the normalized words of a search query, each of them must prefix a term of a matching user
+func searchTokens(q string) []string {
+	return strings.Fields(normalizeSearchText(q))
+}
+
+// searchScore returns the relevance of a matching user to the search tokens, a token scores 1 when it prefixes
+// a term, 2 when it is a whole term and 1 more when it prefixes the username
+func searchScore(user *User, tokens []string) int {
+	score := 0
+	for _, token := range tokens {
+		exact := false
+		for _, term := range user.SearchTerms {
+			if term == token {
+				exact = true
+			}
+		}
+		if exact {
+			score += 2
+		} else {
+			score++
+		}
+		if strings.HasPrefix(user.Username, token) {
+			score++
+		}
+	}
+	return score
+}
+
+// matchesSearch reports whether every search token prefixes at least one search term of the user
+func matchesSearch(user *User, tokens []string) bool {
+	for _, token := range tokens {
+		found := false
+		for _, term := range user.SearchTerms {
+			if strings.HasPrefix(term, token) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// searchMatch returns the MongoDB conditions equivalent to matchesSearch, anchored regular expressions on the
+// indexed search_terms field only scan the index range of the prefix
+func searchMatch(tokens []string) []bson.M {
+	conditions := []bson.M{}
+	for _, token := range tokens {
+		conditions = append(conditions, bson.M{"search_terms": bson.M{"$regex": "^" + regexp.QuoteMeta(token)}})
+	}
+	return conditions
+}
+
+// searchScoreExpression returns the aggregation expression equivalent to searchScore
+func searchScoreExpression(tokens []string) bson.M {
+	scores := bson.A{}
+	for _, token := range tokens {
+		scores = append(scores,
+			bson.M{"$cond": bson.A{bson.M{"$in": bson.A{token, "$search_terms"}}, 2, 1}},
+			bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$indexOfCP": bson.A{"$username", token}}, 0}}, 1, 0}},
+		)
+	}
+	return bson.M{"$add": scores}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestUserSearchTerms(t *testing.T) {
+	lastName := "Núñez-Lie"
+	terms := UserSearchTerms(&User{FirstName: "José", LastName: &lastName, Username: "jose.n"})
+
+	want := []string{"jose.n", "jose", "n", "nunez", "lie"}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("got search terms %v, want %v", terms, want)
+	}
+}
+
+func TestGetUsersRanksSearchByRelevance(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_permissions", AllPermissions)
+	store := NewMemoryUserStore()
+
+	johnson := "Johnson"
+	for _, user := range []User{
+		{FirstName: "Johnny", Username: "walker"},
+		{FirstName: "Mary", LastName: &johnson, Username: "mary"},
+		{FirstName: "John", Username: "john"},
+		{FirstName: "Bob", Username: "bob"},
+		{FirstName: "Jöhn", Username: "smith"},
+	} {
+		user.Roles = []UserRole{General}
+		user.Password = "general"
+		_, err := store.CreateUser(&ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	searches := []struct {
+		q         string
+		usernames []string
+	}{
+		// a whole term scores 2 and a prefix of the username 1 more, every other prefix scores 1
+		{q: "john", usernames: []string{"john", "smith", "walker", "mary"}},
+		{q: "JOHN", usernames: []string{"john", "smith", "walker", "mary"}},
+		{q: "mary jo", usernames: []string{"mary"}},
+		{q: "ohn", usernames: []string{}},
+	}
+	for _, search := range searches {
+		page, err := store.GetUsers(&ctx, &UserFilter{Query: search.q, Sort: []UserSort{{Category: Relevance}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usernames := []string{}
+		for _, user := range page.Users {
+			usernames = append(usernames, user.Username)
+		}
+		// users with the same score are ordered by ID, which follows their creation
+		if !reflect.DeepEqual(usernames, search.usernames) {
+			t.Errorf("searching %q: got %v, want %v", search.q, usernames, search.usernames)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ParseUserSortCategory returns the sort category with the given name
+func ParseUserSortCategory(name string) (UserSortCategory, bool) {
+	category := UserSortCategory(name)
+	switch category {
+	case CreatedAt, UpdatedAt, FirstName, LastName, Username, RoleName, Relevance:
+		return category, true
+	}
+	return "", false
+}
+
+// ParseUserSort parses sort keys separated by commas, each prefixed with - for descending order
+// or optionally + for ascending order, e.g. "last_name,-created_at"
+func ParseUserSort(sort string) ([]UserSort, error) {
+	keys := []UserSort{}
+	seen := map[UserSortCategory]bool{}
+	for _, part := range strings.Split(sort, ",") {
+		key := UserSort{Order: Asc}
+		name := strings.TrimSpace(part)
+		if strings.HasPrefix(name, "-") {
+			key.Order = Desc
+		}
+		name = strings.TrimLeft(name, "+-")
+
+		category, ok := ParseUserSortCategory(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSort, name)
+		}
+		if seen[category] {
+			return nil, fmt.Errorf("%w: %q is repeated", ErrInvalidSort, name)
+		}
+		seen[category] = true
+
+		key.Category = category
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// field returns the field of the user that the category sorts on
+func (category UserSortCategory) field() string {
+	switch category {
+	case Relevance:
+		return "score"
+	case RoleName:
+		return "role_key"
+	}
+	return string(category)
+}
+
+// value returns the value of the user that the category sorts on, nil when the user has no value
+func (category UserSortCategory) value(user *User) interface{} {
+	switch category {
+	case CreatedAt:
+		return user.CreatedAt
+	case UpdatedAt:
+		return user.UpdatedAt
+	case FirstName:
+		return user.FirstName
+	case LastName:
+		if user.LastName == nil {
+			return nil
+		}
+		return *user.LastName
+	case Username:
+		return user.Username
+	case RoleName:
+		return user.RoleKey
+	case Relevance:
+		return user.Score
+	}
+	return nil
+}
+
+// UserRoleKey returns the value users are sorted on by role, the sorted names of their roles joined by commas
+func UserRoleKey(roles []UserRole) string {
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// collated reports whether the category sorts on text that is compared with the "en" collation
+func (category UserSortCategory) collated() bool {
+	switch category {
+	case FirstName, LastName, Username, RoleName:
+		return true
+	}
+	return false
+}
+
+// direction returns 1 for ascending and -1 for descending, the most relevant users always come first
+func (userSort UserSort) direction() int {
+	if userSort.Category == Relevance {
+		return -1
+	}
+	return userSort.Order.Direction()
+}
+
+// sort returns the sort keys requested by the filter, relevance is ignored when not searching
+func (filter *UserFilter) sort() []UserSort {
+	if filter == nil {
+		return nil
+	}
+	searching := len(filter.searchTokens()) > 0
+
+	keys := []UserSort{}
+	for _, key := range filter.Sort {
+		if key.Category == Relevance && !searching {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// userPosition is the place of a user in a sort, the values of the sort keys followed by the ID that breaks ties
+type userPosition struct {
+	values []interface{}
+	id     primitive.ObjectID
+}
+
+func positionOf(user *User, keys []UserSort) userPosition {
+	position := userPosition{id: user.Id}
+	for _, key := range keys {
+		position.values = append(position.values, key.Category.value(user))
+	}
+	return position
+}
+
+// comparePositions returns a negative number when a comes before b in the sort, ties are broken on ascending IDs
+func comparePositions(a userPosition, b userPosition, keys []UserSort) int {
+	for i, key := range keys {
+		if c := compareSortValues(a.values[i], b.values[i]); c != 0 {
+			return c * key.direction()
+		}
+	}
+	return strings.Compare(a.id.Hex(), b.id.Hex())
+}
+
+// compareSortValues compares two values of a sort key the way MongoDB does with the "en" collation,
+// missing values come before any other value
+func compareSortValues(a interface{}, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+
+	switch a := a.(type) {
+	case time.Time:
+		b := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+	case string:
+		b := b.(string)
+		if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+			return c
+		}
+		// lowercase sorts before uppercase when the strings only differ in case
+		return strings.Compare(b, a)
+	case int:
+		return a - b.(int)
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseUserSort(t *testing.T) {
+	keys, err := ParseUserSort("last_name, -created_at,+username")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UserSort{{Category: LastName, Order: Asc}, {Category: CreatedAt, Order: Desc}, {Category: Username, Order: Asc}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got sort keys %v, want %v", keys, want)
+	}
+
+	for _, sort := range []string{"", "age", "last_name,", "first_name,-first_name"} {
+		_, err := ParseUserSort(sort)
+		if !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("parsing %q: got error %v, want ErrInvalidSort", sort, err)
+		}
+	}
+}
+
+func TestGetUsersMultiKeySortPages(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user_permissions", AllPermissions)
+	store := NewMemoryUserStore()
+
+	lie := "Lie"
+	tan := "Tan"
+	for i, user := range []User{
+		{Roles: []UserRole{General}, FirstName: "Ani", LastName: &tan},
+		{Roles: []UserRole{Admin}, FirstName: "Budi", LastName: &lie},
+		{Roles: []UserRole{General}, FirstName: "Citra"},
+		{Roles: []UserRole{General}, FirstName: "Dewi", LastName: &lie},
+		{Roles: []UserRole{Admin}, FirstName: "Eka"},
+		{Roles: []UserRole{General}, FirstName: "Fajar", LastName: &lie},
+		{Roles: []UserRole{General}, FirstName: "Gita", LastName: &tan},
+	} {
+		user.Username = "user" + string(rune('a'+i))
+		user.Password = "general"
+		_, err := store.CreateUser(&ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := ParseUserSort("role,last_name,-first_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// users without a last name come first, ties on the last name are ordered by descending first name
+	want := []string{"Eka", "Budi", "Citra", "Fajar", "Dewi", "Gita", "Ani"}
+	got := []string{}
+	cursor := ""
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		if pages > 3 {
+			t.Fatalf("the pages never end")
+		}
+
+		page, err := store.GetUsers(&ctx, &UserFilter{Sort: keys, Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, user := range page.Users {
+			got = append(got, user.FirstName)
+		}
+		cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got users %v across the pages, want %v", got, want)
+	}
+
+	page, err := store.GetUsers(&ctx, &UserFilter{Sort: keys, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sort := range []string{"role,last_name", "role,last_name,first_name", "-role,last_name,-first_name"} {
+		otherKeys, err := ParseUserSort(sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.GetUsers(&ctx, &UserFilter{Sort: otherKeys, Limit: 2, Cursor: page.NextCursor})
+		if err != ErrInvalidCursor {
+			t.Errorf("reusing a cursor with the sort %s: got error %v, want ErrInvalidCursor", sort, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"SejutaCita/common"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// VisibilityRule restricts the users that the client in the context is allowed to see when listing users.
+// Every rule is expressed both as a query predicate for MongoDB and as a check for the in-memory store.
+type VisibilityRule interface {
+	// Match returns the condition that visible users satisfy, nil when the rule does not restrict the client
+	Match(ctx context.Context) bson.M
+	// Allows reports whether the user is visible to the client, it must agree with Match
+	Allows(ctx context.Context, user *User) bool
+}
+
+// visibilityRules are the rules applied by GetUsers, a user is visible when every rule allows it
+var visibilityRules = []VisibilityRule{policyVisibility{}}
+
+// RegisterVisibilityRule adds a rule to the ones applied by GetUsers
+func RegisterVisibilityRule(rule VisibilityRule) {
+	visibilityRules = append(visibilityRules, rule)
+}
+
+// visibilityMatch returns the conditions of every visibility rule restricting the client
+func visibilityMatch(ctx context.Context) []bson.M {
+	conditions := []bson.M{}
+	for _, rule := range visibilityRules {
+		if condition := rule.Match(ctx); condition != nil {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
+// isVisible reports whether every visibility rule allows the client to see the user
+func isVisible(ctx context.Context, user *User) bool {
+	for _, rule := range visibilityRules {
+		if !rule.Allows(ctx, user) {
+			return false
+		}
+	}
+	return true
+}
+
+// policyVisibility lets clients see themselves and the users that their roles or the policies let them read, minus
+// the ones the policies deny them. Contexts without a client, used internally, are not restricted.
+type policyVisibility struct{}
+
+func (policyVisibility) Match(ctx context.Context) bson.M {
+	userId, ok := ctx.Value("user_id").(string)
+	if !ok {
+		return nil
+	}
+
+	match := CurrentPolicies().visibleMatch(SubjectFromContext(ctx), PermissionUsersRead)
+	if match == nil {
+		return nil
+	}
+	return bson.M{"$or": bson.A{bson.M{"_id": common.ObjectIDFromHex(userId)}, match}}
+}
+
+func (policyVisibility) Allows(ctx context.Context, user *User) bool {
+	userId, ok := ctx.Value("user_id").(string)
+	if !ok || user.Id.Hex() == userId {
+		return true
+	}
+	return CurrentPolicies().Decide(SubjectFromContext(ctx), PermissionUsersRead, user.PolicyAttributes()).Allowed
+}
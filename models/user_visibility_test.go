@@ -0,0 +1,195 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// evaluateMatch reports whether the document satisfies the MongoDB predicate, it supports the operators the
+// visibility rules use: $and, $or, $nor, $in, $nin and equality
+func evaluateMatch(t *testing.T, document bson.M, match bson.M) bool {
+	t.Helper()
+
+	for key, value := range match {
+		switch key {
+		case "$and", "$or", "$nor":
+			matched := 0
+			for _, condition := range value.(bson.A) {
+				if evaluateMatch(t, document, condition.(bson.M)) {
+					matched++
+				}
+			}
+			count := len(value.(bson.A))
+			if key == "$and" && matched != count || key == "$or" && matched == 0 || key == "$nor" && matched > 0 {
+				return false
+			}
+		default:
+			values := fieldValues(document, key)
+			operators, ok := value.(bson.M)
+			if !ok {
+				if !containsAny(values, bson.A{value}) {
+					return false
+				}
+				continue
+			}
+			for operator, operand := range operators {
+				switch operator {
+				case "$in":
+					if !containsAny(values, operand.(bson.A)) {
+						return false
+					}
+				case "$nin":
+					if containsAny(values, operand.(bson.A)) {
+						return false
+					}
+				default:
+					t.Fatalf("unsupported operator %s", operator)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// fieldValues returns the values a predicate on the dotted path compares, the elements of an array and nil for a
+// missing field as MongoDB does
+func fieldValues(document bson.M, path string) bson.A {
+	var value interface{} = document
+	for _, name := range strings.Split(path, ".") {
+		nested, ok := value.(bson.M)
+		if !ok {
+			return bson.A{nil}
+		}
+		value = nested[name]
+	}
+	if array, ok := value.(bson.A); ok {
+		return array
+	}
+	return bson.A{value}
+}
+
+func containsAny(values bson.A, others bson.A) bool {
+	for _, value := range values {
+		for _, other := range others {
+			if reflect.DeepEqual(value, other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibilityUser returns a user with the roles and the attributes
+func visibilityUser(username string, roles []UserRole, attributes map[string]string) *User {
+	return &User{Id: primitive.NewObjectID(), Username: username, Roles: roles, Attributes: attributes}
+}
+
+func TestPolicyVisibilityMatchAgreesWithAllows(t *testing.T) {
+	alice := visibilityUser("alice", []UserRole{General}, map[string]string{"organization": "acme", "level": "1"})
+	bob := visibilityUser("bob", []UserRole{General}, map[string]string{"organization": "globex"})
+	carol := visibilityUser("carol", []UserRole{Admin}, map[string]string{"organization": "acme"})
+	dave := visibilityUser("dave", []UserRole{General}, nil)
+	erin := visibilityUser("erin", []UserRole{General, "Support"}, map[string]string{"organization": "acme", "level": "2", "team": "support"})
+	users := []*User{alice, bob, carol, dave, erin}
+
+	sets := map[string]*PolicySet{
+		"no policies": {},
+		"same organization": {Policies: []*Policy{{
+			Id: "same-organization", Effect: PolicyAllow, Actions: []Permission{PermissionUsersRead},
+			Subject:  AttributeConditions{"roles": {In: []string{string(General)}}},
+			Resource: AttributeConditions{"attributes.organization": {In: []string{"$subject.attributes.organization"}}},
+		}}},
+		"hidden admins": {Policies: []*Policy{{
+			Id: "hidden-admins", Effect: PolicyDeny, Actions: []Permission{PolicyAnyAction},
+			Subject:  AttributeConditions{"roles": {NotIn: []string{string(Admin)}}},
+			Resource: AttributeConditions{"roles": {In: []string{string(Admin)}}},
+		}}},
+		"support team": {Policies: []*Policy{{
+			Id: "support-team", Effect: PolicyAllow, Actions: []Permission{PermissionUsersRead},
+			Subject: AttributeConditions{"attributes.team": {In: []string{"support"}}},
+		}, {
+			Id: "other-organizations", Effect: PolicyDeny, Actions: []Permission{PermissionUsersRead},
+			Resource: AttributeConditions{"attributes.organization": {NotIn: []string{"$subject.attributes.organization"}}},
+		}}},
+		"named users": {Policies: []*Policy{{
+			Id: "by-id", Effect: PolicyAllow, Actions: []Permission{PermissionUsersRead},
+			Resource: AttributeConditions{"id": {In: []string{alice.Id.Hex(), bob.Id.Hex(), "not-an-id"}}},
+		}, {
+			Id: "not-bob", Effect: PolicyDeny, Actions: []Permission{PermissionUsersRead},
+			Resource: AttributeConditions{"username": {In: []string{"bob"}}},
+		}}},
+		"levels": {Policies: []*Policy{{
+			Id: "levels", Effect: PolicyAllow, Actions: []Permission{PermissionUsersRead},
+			Resource: AttributeConditions{
+				"roles":            {NotIn: []string{string(Admin)}},
+				"attributes.level": {In: []string{"1", "2"}},
+			},
+		}}},
+		"deny everything": {Policies: []*Policy{{
+			Id: "nobody", Effect: PolicyDeny, Actions: []Permission{PermissionUsersRead},
+		}}},
+	}
+
+	type subject struct {
+		user        *User
+		permissions Permissions
+		scopes      Permissions
+	}
+	subjects := map[string]subject{
+		"alice":                    {user: alice},
+		"bob":                      {user: bob},
+		"carol":                    {user: carol, permissions: AllPermissions},
+		"dave":                     {user: dave},
+		"erin":                     {user: erin, permissions: Permissions{PermissionUsersRead}},
+		"carol with a roles token": {user: carol, permissions: AllPermissions, scopes: Permissions{PermissionRolesRead}},
+		"erin with a users token":  {user: erin, permissions: Permissions{PermissionUsersRead}, scopes: Permissions{PermissionUsersRead}},
+	}
+
+	documents := map[*User]bson.M{}
+	for _, user := range users {
+		data, err := bson.Marshal(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		document := bson.M{}
+		err = bson.Unmarshal(data, &document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		documents[user] = document
+	}
+
+	defer UsePolicies(CurrentPolicies())
+	for setName, set := range sets {
+		err := set.Validate()
+		if err != nil {
+			t.Fatalf("%s: %s", setName, err)
+		}
+		UsePolicies(set)
+
+		for subjectName, s := range subjects {
+			ctx := context.WithValue(context.Background(), "user_id", s.user.Id.Hex())
+			ctx = context.WithValue(ctx, "user_roles", s.user.Roles)
+			ctx = context.WithValue(ctx, "user_attributes", s.user.Attributes)
+			ctx = context.WithValue(ctx, "user_permissions", s.permissions)
+			if s.scopes != nil {
+				ctx = context.WithValue(ctx, "token_scopes", s.scopes)
+			}
+
+			match := policyVisibility{}.Match(ctx)
+			for _, user := range users {
+				allowed := policyVisibility{}.Allows(ctx, user)
+				matched := match == nil || evaluateMatch(t, documents[user], match)
+				if allowed != matched {
+					t.Errorf("%s, %s sees %s: the in-memory store says %t, MongoDB %t with %v", setName, subjectName,
+						user.Username, allowed, matched, match)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"SejutaCita/handlers"
+	"SejutaCita/middleware"
+	"SejutaCita/models"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func AccessTokenRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, accessTokens models.AccessTokenStore) {
+	handler := handlers.NewAccessTokenHandler(l, accessTokens)
+
+	// the tokens are managed from a session only, so that a leaked token can not mint or revoke other tokens
+	register(r, table, []Route{
+		{Method: http.MethodGet, Path: "/me/tokens", Access: middleware.Authenticated().WithSession(), Handler: handler.GetAccessTokens},
+		{Method: http.MethodPost, Path: "/me/token", Access: middleware.Authenticated().WithSession(), Handler: handler.CreateAccessToken},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/me/token",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Authenticated().WithSession(),
+			Handler: handler.RevokeAccessToken,
+		},
+	})
+}
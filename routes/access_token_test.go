@@ -0,0 +1,134 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// createAccessToken creates a personal access token limited to the scopes and returns it
+func createAccessToken(t *testing.T, s *testServer, token string, scopes ...models.Permission) models.CreatedAccessToken {
+	t.Helper()
+
+	res := s.request(http.MethodPost, "/me/token", token, map[string]interface{}{
+		"name":       "ci",
+		"scopes":     scopes,
+		"expires_at": time.Now().Add(time.Hour),
+	})
+	if res.status != http.StatusOK {
+		t.Fatalf("creating a personal access token: got status %d: %s", res.status, res.body)
+	}
+	accessToken := models.CreatedAccessToken{}
+	res.decode(&accessToken)
+	return accessToken
+}
+
+func TestAccessTokenStaysWithinItsScopes(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	accessToken := createAccessToken(t, s, admin.Token, models.PermissionUsersRead)
+
+	res := s.request(http.MethodGet, "/users", accessToken.Token, nil)
+	expectStatus(t, "a route within the scopes", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/roles", accessToken.Token, nil)
+	expectStatus(t, "a route outside the scopes", res, http.StatusForbidden)
+
+	me := struct {
+		Id string `json:"id"`
+	}{}
+	s.request(http.MethodGet, "/me", admin.Token, nil).decode(&me)
+	res = s.request(http.MethodPut, "/user?id="+me.Id, accessToken.Token, map[string]interface{}{
+		"first_name": "Mallory",
+	})
+	expectStatus(t, "updating its own user outside the scopes", res, http.StatusForbidden)
+
+	// the self-service routes need a session, whatever the scopes
+	for _, route := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/me"},
+		{http.MethodPatch, "/me"},
+		{http.MethodGet, "/sessions"},
+		{http.MethodGet, "/me/tokens"},
+		{http.MethodPost, "/me/password"},
+	} {
+		res = s.request(route.method, route.path, accessToken.Token, map[string]string{})
+		expectStatus(t, route.method+" "+route.path, res, http.StatusForbidden)
+	}
+}
+
+func TestAccessTokenScopesAreHeldByTheUser(t *testing.T) {
+	s := newTestServer(t)
+	general := s.login("general", "general")
+
+	res := s.request(http.MethodPost, "/me/token", general.Token, map[string]interface{}{
+		"name":       "ci",
+		"scopes":     models.Permissions{},
+		"expires_at": time.Now().Add(time.Hour),
+	})
+	expectStatus(t, "no scopes", res, http.StatusBadRequest)
+
+	res = s.request(http.MethodPost, "/me/token", general.Token, map[string]interface{}{
+		"name":       "ci",
+		"scopes":     models.Permissions{models.PermissionUsersRead},
+		"expires_at": time.Now().Add(time.Hour),
+	})
+	expectStatus(t, "a scope the user does not hold", res, http.StatusForbidden)
+
+	admin := s.login("admin", "admin")
+	res = s.request(http.MethodPost, "/me/token", admin.Token, map[string]interface{}{
+		"name":       "ci",
+		"scopes":     models.Permissions{models.PermissionUsersRead},
+		"expires_at": time.Now().Add(-time.Hour),
+	})
+	expectStatus(t, "an expiry in the past", res, http.StatusBadRequest)
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	accessToken := createAccessToken(t, s, admin.Token, models.PermissionUsersRead)
+
+	res := s.request(http.MethodDelete, "/me/token?id="+accessToken.Id, admin.Token, nil)
+	expectStatus(t, "revoking the token", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/users", accessToken.Token, nil)
+	expectStatus(t, "the revoked token", res, http.StatusUnauthorized)
+}
+
+func TestChangePasswordRevokesAccessTokens(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("rotating", models.General, models.Admin)
+	session := s.login("rotating", testPassword)
+	accessToken := createAccessToken(t, s, session.Token, models.PermissionUsersRead)
+
+	res := s.request(http.MethodPost, "/me/password", session.Token, map[string]string{
+		"current_password": testPassword,
+		"new_password":     "Kw3$nTx9bQ",
+	})
+	expectStatus(t, "changing the password", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/users", accessToken.Token, nil)
+	expectStatus(t, "the token created before the change", res, http.StatusUnauthorized)
+}
+
+func TestPasswordResetRevokesEveryToken(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createUser("compromised", models.General, models.Admin)
+	session := s.login("compromised", testPassword)
+	accessToken := createAccessToken(t, s, session.Token, models.PermissionUsersRead)
+
+	admin := s.login("admin", "admin")
+	res := s.request(http.MethodPut, "/user?id="+id, admin.Token, map[string]string{"password": "Kw3$nTx9bQ"})
+	expectStatus(t, "resetting the password", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/users", accessToken.Token, nil)
+	expectStatus(t, "the personal access token created before the reset", res, http.StatusUnauthorized)
+	res = s.request(http.MethodGet, "/me", session.Token, nil)
+	expectStatus(t, "the access token issued before the reset", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": session.RefreshToken})
+	expectStatus(t, "the refresh token issued before the reset", res, http.StatusUnauthorized)
+}
@@ -2,16 +2,35 @@ package routes
 
 import (
 	"SejutaCita/handlers"
+	"SejutaCita/middleware"
+	"SejutaCita/models"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func AuthRoutes(r *mux.Router, l *log.Logger) {
-	handler := handlers.NewAuthHandler(l)
+func AuthRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, store models.UserStore, tokens models.TokenStore, attempts models.LoginAttemptStore) {
+	handler := handlers.NewAuthHandler(l, store, tokens, attempts)
 
-	postRouter := r.Methods(http.MethodPost).Subrouter()
-	postRouter.HandleFunc("/login", handler.Login)
-	postRouter.Use(handler.MiddlewareValidateLogin)
+	register(r, table, []Route{
+		{
+			Method:      http.MethodPost,
+			Path:        "/login",
+			Access:      middleware.Public(),
+			Handler:     handler.Login,
+			Middlewares: []mux.MiddlewareFunc{handler.MiddlewareValidateLogin},
+		},
+		{Method: http.MethodPost, Path: "/token/refresh", Access: middleware.Public(), Handler: handler.RefreshToken},
+		{Method: http.MethodGet, Path: "/.well-known/jwks.json", Access: middleware.Public(), Handler: handler.GetJWKS},
+		{Method: http.MethodPost, Path: "/logout", Access: middleware.Authenticated().WithSession(), Handler: handler.Logout},
+		{Method: http.MethodPost, Path: "/logout/all", Access: middleware.Authenticated().WithSession(), Handler: handler.LogoutAll},
+		{
+			Method:  http.MethodPost,
+			Path:    "/user/unlock",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Permissions(models.PermissionUsersWrite).OnUser(),
+			Handler: handler.UnlockUser,
+		},
+	})
 }
@@ -0,0 +1,294 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestServer(t)
+	first := s.login("general", "general")
+	ctx := context.Background()
+	general, err := s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": first.RefreshToken})
+	expectStatus(t, "refresh", res, http.StatusOK)
+	second := models.UserToken{}
+	res.decode(&second)
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("the refresh token was not rotated")
+	}
+
+	res = s.request(http.MethodGet, "/user?id="+general.Id.Hex(), second.Token, nil)
+	expectStatus(t, "the refreshed access token", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/user?id="+general.Id.Hex(), first.RefreshToken, nil)
+	expectStatus(t, "a refresh token as an access token", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": second.Token})
+	expectStatus(t, "an access token as a refresh token", res, http.StatusUnauthorized)
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	s := newTestServer(t)
+	first := s.login("general", "general")
+	ctx := context.Background()
+	general, err := s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/user?id=" + general.Id.Hex()
+
+	res := s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": first.RefreshToken})
+	expectStatus(t, "refresh", res, http.StatusOK)
+	second := models.UserToken{}
+	res.decode(&second)
+
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": first.RefreshToken})
+	expectStatus(t, "reused refresh token", res, http.StatusUnauthorized)
+	if res.message() != models.ErrRefreshTokenReused.Error() {
+		t.Errorf("reused refresh token: got message %q", res.message())
+	}
+
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": second.RefreshToken})
+	expectStatus(t, "the latest refresh token of the family", res, http.StatusUnauthorized)
+	for name, token := range map[string]string{"first": first.Token, "second": second.Token} {
+		res = s.request(http.MethodGet, path, token, nil)
+		expectStatus(t, "the "+name+" access token of the family", res, http.StatusUnauthorized)
+	}
+
+	// another login starts a family of its own
+	other := s.login("general", "general")
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": other.RefreshToken})
+	expectStatus(t, "the refresh token of another family", res, http.StatusOK)
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "1")
+	s := newTestServer(t)
+	id := s.createUser("locked", models.General)
+
+	res := s.request(http.MethodPost, "/login", "", map[string]string{"username": "locked", "password": "wrong"})
+	expectStatus(t, "wrong password", res, http.StatusUnauthorized)
+
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "locked", "password": testPassword})
+	expectStatus(t, "correct password while locked out", res, http.StatusTooManyRequests)
+	if res.message() != models.ErrLoginLocked.Error() || res.header.Get("Retry-After") == "" {
+		t.Errorf("locked out login: got message %q and Retry-After %q", res.message(), res.header.Get("Retry-After"))
+	}
+
+	admin := s.login("admin", "admin")
+	res = s.request(http.MethodPost, "/user/unlock?id="+id, admin.Token, nil)
+	expectStatus(t, "unlock", res, http.StatusOK)
+	s.login("locked", testPassword)
+}
+
+func TestLoginThrottlesUnknownUsernames(t *testing.T) {
+	s := newTestServer(t)
+
+	res := s.request(http.MethodPost, "/login", "", map[string]string{"username": "nobody", "password": "wrong"})
+	expectStatus(t, "unknown username", res, http.StatusUnauthorized)
+	if res.message() != models.ErrIncorrectCredentials.Error() {
+		t.Errorf("unknown username: got message %q", res.message())
+	}
+
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "nobody", "password": "wrong"})
+	expectStatus(t, "unknown username right after a failure", res, http.StatusTooManyRequests)
+}
+
+func TestLoginIPLockout(t *testing.T) {
+	t.Setenv("LOGIN_IP_LOCKOUT_THRESHOLD", "2")
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.1")
+	s := newTestServer(t)
+
+	// the clients behind the trusted proxy are told apart by the X-Forwarded-For header
+	for _, username := range []string{"first", "second"} {
+		res := s.request(http.MethodPost, "/login", "", map[string]string{"username": username, "password": "wrong"},
+			"X-Forwarded-For", "203.0.113.7")
+		expectStatus(t, "failed login of "+username, res, http.StatusUnauthorized)
+	}
+
+	res := s.request(http.MethodPost, "/login", "", map[string]string{"username": "general", "password": "general"},
+		"X-Forwarded-For", "203.0.113.7")
+	expectStatus(t, "login from the locked out address", res, http.StatusTooManyRequests)
+
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "general", "password": "general"},
+		"X-Forwarded-For", "198.51.100.1")
+	expectStatus(t, "login from another address behind the proxy", res, http.StatusOK)
+
+	// the leftmost addresses are sent by the client and do not escape the lockout
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "general", "password": "general"},
+		"X-Forwarded-For", "198.51.100.2, 203.0.113.7")
+	expectStatus(t, "login with a forged address", res, http.StatusTooManyRequests)
+
+	ctx := context.Background()
+	general, err := s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := s.login("admin", "admin")
+	res = s.request(http.MethodPost, "/user/unlock?id="+general.Id.Hex()+"&ip=203.0.113", admin.Token, nil)
+	expectStatus(t, "unlocking a malformed address", res, http.StatusBadRequest)
+	res = s.request(http.MethodPost, "/user/unlock?id="+general.Id.Hex()+"&ip=203.0.113.7", admin.Token, nil)
+	expectStatus(t, "unlocking the address", res, http.StatusOK)
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "general", "password": "general"},
+		"X-Forwarded-For", "203.0.113.7")
+	expectStatus(t, "login from the unlocked address", res, http.StatusOK)
+}
+
+func TestLoginRehashesOutdatedPasswords(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	general, err := s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := (&models.BcryptHasher{Cost: 4}).Hash("general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.users.UpdatePasswordHash(&ctx, general.Id, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.login("general", "general")
+	general, err = s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(general.Password, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("the bcrypt hash was not upgraded on login: %s", general.Password)
+	}
+	s.login("general", "general")
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	general, err := s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/user?id=" + general.Id.Hex()
+
+	current := s.login("general", "general")
+	other := s.login("general", "general")
+	res := s.request(http.MethodPost, "/logout", current.Token, nil)
+	expectStatus(t, "logout", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, path, current.Token, nil)
+	expectStatus(t, "the access token after the logout", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": current.RefreshToken})
+	expectStatus(t, "the refresh token after the logout", res, http.StatusUnauthorized)
+	res = s.request(http.MethodGet, path, other.Token, nil)
+	expectStatus(t, "the access token of another session", res, http.StatusOK)
+
+	res = s.request(http.MethodPost, "/logout/all", other.Token, nil)
+	expectStatus(t, "logout of every session", res, http.StatusOK)
+	res = s.request(http.MethodGet, path, other.Token, nil)
+	expectStatus(t, "the access token after the logout of every session", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": other.RefreshToken})
+	expectStatus(t, "the refresh token after the logout of every session", res, http.StatusUnauthorized)
+
+	// a login right after the logout of every session is not covered by it
+	next := s.login("general", "general")
+	res = s.request(http.MethodGet, path, next.Token, nil)
+	expectStatus(t, "the access token of a login after the logout of every session", res, http.StatusOK)
+}
+
+func TestLogoutAllEndsEverySession(t *testing.T) {
+	s := newTestServer(t)
+	first := s.login("general", "general")
+	second := s.login("general", "general")
+
+	res := s.request(http.MethodPost, "/logout/all", first.Token, nil)
+	expectStatus(t, "logging out everywhere", res, http.StatusOK)
+
+	// the tokens were issued in the second of the logout, which the revocation of the user spares
+	for name, tokens := range map[string]models.UserToken{"first": first, "second": second} {
+		res = s.request(http.MethodGet, "/sessions", tokens.Token, nil)
+		expectStatus(t, "the access token of the "+name+" session", res, http.StatusUnauthorized)
+		res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": tokens.RefreshToken})
+		expectStatus(t, "the refresh token of the "+name+" session", res, http.StatusUnauthorized)
+	}
+	s.login("general", "general")
+}
+
+// tokenKeyId returns the kid header of the token
+func tokenKeyId(t *testing.T, token string) string {
+	t.Helper()
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := struct {
+		Kid string `json:"kid"`
+	}{}
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header.Kid
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	s := newTestServer(t)
+	first := s.login("general", "general")
+	ctx := context.Background()
+	general, err := s.users.GetUserByUsername(&ctx, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := models.GenerateSigningKey(s.keys, models.SigningAlgorithm())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = models.PromoteSigningKey(s.keys, key.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := s.login("general", "general")
+	if tokenKeyId(t, second.Token) != key.Id {
+		t.Errorf("the token is not signed with the promoted key")
+	}
+	oldKey := tokenKeyId(t, first.Token)
+	if oldKey == key.Id {
+		t.Fatalf("the token signed before the rotation names the promoted key")
+	}
+
+	res := s.request(http.MethodGet, "/user?id="+general.Id.Hex(), first.Token, nil)
+	expectStatus(t, "a token signed with the retired key", res, http.StatusOK)
+	res = s.request(http.MethodGet, "/user?id="+general.Id.Hex(), second.Token, nil)
+	expectStatus(t, "a token signed with the promoted key", res, http.StatusOK)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": first.RefreshToken})
+	expectStatus(t, "a refresh token signed with the retired key", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/.well-known/jwks.json", "", nil)
+	expectStatus(t, "jwks", res, http.StatusOK)
+	jwks := models.JSONWebKeySet{}
+	res.decode(&jwks)
+	kids := map[string]bool{}
+	for _, jwk := range jwks.Keys {
+		kids[jwk.Kid] = true
+		if jwk.Use != "sig" || jwk.Alg != models.SigningAlgorithm() {
+			t.Errorf("key %s: got use %q and alg %q", jwk.Kid, jwk.Use, jwk.Alg)
+		}
+	}
+	if len(kids) != 2 || !kids[oldKey] || !kids[key.Id] {
+		t.Errorf("jwks: got keys %v, want %s and %s", kids, oldKey, key.Id)
+	}
+	if strings.Contains(string(res.body), "PRIVATE") || strings.Contains(string(res.body), `"d"`) {
+		t.Errorf("jwks holds a private key: %s", res.body)
+	}
+}
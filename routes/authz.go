@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"SejutaCita/handlers"
+	"SejutaCita/middleware"
+	"SejutaCita/models"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func AuthzRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, roles models.RoleStore) {
+	handler := handlers.NewAuthzHandler(l, roles)
+
+	register(r, table, []Route{
+		{Method: http.MethodPost, Path: "/authz/check", Access: middleware.Permissions(models.PermissionPoliciesCheck), Handler: handler.CheckAuthz},
+	})
+}
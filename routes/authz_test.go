@@ -0,0 +1,114 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"net/http"
+	"testing"
+)
+
+func TestCheckAuthz(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	general := s.login("general", "general")
+	models.UsePolicies(&models.PolicySet{Policies: []*models.Policy{{
+		Id:       "same-organization",
+		Effect:   models.PolicyAllow,
+		Actions:  []models.Permission{models.PermissionUsersRead},
+		Resource: models.AttributeConditions{"attributes.organization": {In: []string{"$subject.attributes.organization"}}},
+	}}})
+
+	check := func(organization string) map[string]interface{} {
+		return map[string]interface{}{
+			"subject": map[string]interface{}{
+				"id":         "member",
+				"roles":      []models.UserRole{models.General},
+				"attributes": map[string]string{"organization": "acme"},
+			},
+			"action": models.PermissionUsersRead,
+			"resource": map[string]interface{}{
+				"id":         "colleague",
+				"roles":      []models.UserRole{models.General},
+				"username":   "colleague",
+				"attributes": map[string]string{"organization": organization},
+			},
+		}
+	}
+
+	decision := models.AuthzDecision{}
+	res := s.request(http.MethodPost, "/authz/check", admin.Token, check("acme"))
+	expectStatus(t, "checking a request the policy allows", res, http.StatusOK)
+	res.decode(&decision)
+	if !decision.Allowed || decision.Policy != "same-organization" {
+		t.Errorf("checking a request the policy allows: got %s", res.body)
+	}
+
+	decision = models.AuthzDecision{}
+	res = s.request(http.MethodPost, "/authz/check", admin.Token, check("other"))
+	expectStatus(t, "checking a request no policy allows", res, http.StatusOK)
+	res.decode(&decision)
+	if decision.Allowed {
+		t.Errorf("checking a request no policy allows: got %s", res.body)
+	}
+
+	res = s.request(http.MethodPost, "/authz/check", admin.Token, map[string]interface{}{"action": "users:fly"})
+	expectStatus(t, "checking an unknown action", res, http.StatusBadRequest)
+	res = s.request(http.MethodPost, "/authz/check", general.Token, check("acme"))
+	expectStatus(t, "checking without policies:check", res, http.StatusForbidden)
+}
+
+func TestPoliciesApplyToRoutes(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	for _, username := range []string{"member", "colleague", "outsider"} {
+		organization := "acme"
+		if username == "outsider" {
+			organization = "other"
+		}
+		res := s.request(http.MethodPost, "/user", admin.Token, map[string]interface{}{
+			"first_name": "Member",
+			"username":   username,
+			"password":   testPassword,
+			"roles":      []models.UserRole{models.General},
+			"attributes": map[string]string{"organization": organization},
+		})
+		expectStatus(t, "creating "+username, res, http.StatusOK)
+	}
+	ids := map[string]string{}
+	res := s.request(http.MethodGet, "/users?include_deleted=false", admin.Token, nil)
+	page := struct {
+		Users []struct {
+			Id       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"users"`
+	}{}
+	res.decode(&page)
+	for _, user := range page.Users {
+		ids[user.Username] = user.Id
+	}
+
+	models.UsePolicies(&models.PolicySet{Policies: []*models.Policy{{
+		Id:       "same-organization",
+		Effect:   models.PolicyAllow,
+		Actions:  []models.Permission{models.PermissionUsersRead},
+		Resource: models.AttributeConditions{"attributes.organization": {In: []string{"$subject.attributes.organization"}}},
+	}}})
+	member := s.login("member", testPassword)
+
+	res = s.request(http.MethodGet, "/user?id="+ids["colleague"], member.Token, nil)
+	expectStatus(t, "getting a user of the organization", res, http.StatusOK)
+	res = s.request(http.MethodGet, "/user?id="+ids["outsider"], member.Token, nil)
+	expectStatus(t, "getting a user of another organization", res, http.StatusForbidden)
+
+	res = s.request(http.MethodGet, "/users", member.Token, nil)
+	expectStatus(t, "listing the users of the organization", res, http.StatusOK)
+	page.Users = nil
+	res.decode(&page)
+	for _, user := range page.Users {
+		if user.Username != "member" && user.Username != "colleague" {
+			t.Errorf("listing the users of the organization: got %s", user.Username)
+		}
+	}
+	if len(page.Users) != 2 {
+		t.Errorf("listing the users of the organization: got %d users, want 2", len(page.Users))
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"SejutaCita/middleware"
+	"net/http"
+
+	openapi "github.com/go-openapi/runtime/middleware"
+	"github.com/gorilla/mux"
+)
+
+// DocsRoutes serves the swagger documentation
+func DocsRoutes(r *mux.Router, table *middleware.AccessTable) {
+	opts := openapi.RedocOpts{SpecURL: "/swagger.yaml"}
+
+	register(r, table, []Route{
+		{Method: http.MethodGet, Path: "/docs", Access: middleware.Public(), Handler: openapi.Redoc(opts, nil).ServeHTTP},
+		{Method: http.MethodGet, Path: "/swagger.yaml", Access: middleware.Public(), Handler: http.FileServer(http.Dir("./")).ServeHTTP},
+	})
+}
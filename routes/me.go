@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"SejutaCita/handlers"
+	"SejutaCita/middleware"
+	"SejutaCita/models"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func MeRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, store models.UserStore, tokens models.TokenStore, attempts models.LoginAttemptStore, accessTokens models.AccessTokenStore) {
+	handler := handlers.NewMeHandler(l, store, tokens, attempts, accessTokens)
+
+	// the profile of the client is not covered by any scope, so the routes are called from a session only
+	register(r, table, []Route{
+		{Method: http.MethodGet, Path: "/me", Access: middleware.Authenticated().WithSession(), Handler: handler.GetMe},
+		{Method: http.MethodPatch, Path: "/me", Access: middleware.Authenticated().WithSession(), Handler: handler.UpdateMe},
+		{Method: http.MethodPost, Path: "/me/password", Access: middleware.Authenticated().WithSession(), Handler: handler.ChangePassword},
+	})
+}
@@ -0,0 +1,113 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"net/http"
+	"testing"
+)
+
+func TestChangePassword(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createUser("changing", models.General)
+	current := s.login("changing", testPassword)
+	other := s.login("changing", testPassword)
+
+	res := s.request(http.MethodPost, "/me/password", current.Token, map[string]string{
+		"current_password": testPassword,
+		"new_password":     testPassword,
+	})
+	expectStatus(t, "reusing the current password", res, http.StatusBadRequest)
+
+	res = s.request(http.MethodPost, "/me/password", current.Token, map[string]string{
+		"current_password": testPassword,
+		"new_password":     "Qw7!xTr9kP",
+	})
+	expectStatus(t, "changing the password", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/user?id="+id, current.Token, nil)
+	expectStatus(t, "the token of the session changing the password", res, http.StatusOK)
+	res = s.request(http.MethodGet, "/user?id="+id, other.Token, nil)
+	expectStatus(t, "the token of another session", res, http.StatusUnauthorized)
+	renewed := s.login("changing", "Qw7!xTr9kP")
+
+	// the previous password is remembered by the history
+	res = s.request(http.MethodPost, "/me/password", renewed.Token, map[string]string{
+		"current_password": "Qw7!xTr9kP",
+		"new_password":     testPassword,
+	})
+	expectStatus(t, "going back to the previous password", res, http.StatusBadRequest)
+	body := models.PasswordPolicyError{}
+	res.decode(&body)
+	if len(body.Violations) != 1 || body.Violations[0].Rule != "history" {
+		t.Errorf("going back to the previous password: got %s", res.body)
+	}
+
+	// a wrong current password counts as a failed login
+	res = s.request(http.MethodPost, "/me/password", renewed.Token, map[string]string{
+		"current_password": testPassword,
+		"new_password":     "Mn4$bVc8zX",
+	})
+	expectStatus(t, "changing with a wrong current password", res, http.StatusForbidden)
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "changing", "password": "Qw7!xTr9kP"})
+	expectStatus(t, "logging in right after the wrong current password", res, http.StatusTooManyRequests)
+}
+
+func TestUpdateMe(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("profile", models.General)
+	client := s.login("profile", testPassword)
+
+	res := s.request(http.MethodPatch, "/me", client.Token, map[string]interface{}{
+		"last_name":   "Lie",
+		"email":       "profile@example.com",
+		"phone":       "+6281234567890",
+		"preferences": map[string]string{"language": "id", "timezone": "Asia/Jakarta"},
+	})
+	expectStatus(t, "updating the profile", res, http.StatusOK)
+
+	res = s.request(http.MethodPatch, "/me", client.Token, map[string]interface{}{
+		"email":       "",
+		"preferences": map[string]string{"timezone": "Europe/Paris"},
+	})
+	expectStatus(t, "clearing the email and changing the time zone", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/me", client.Token, nil)
+	expectStatus(t, "getting the profile", res, http.StatusOK)
+	me := models.UserSelfView{}
+	res.decode(&me)
+	if me.FirstName != "Profile" || me.LastName == nil || *me.LastName != "Lie" || me.Email != nil ||
+		me.Phone == nil || *me.Phone != "+6281234567890" || me.Preferences.Language != "id" || me.Preferences.Timezone != "Europe/Paris" {
+		t.Errorf("the profile was not updated: %s", res.body)
+	}
+
+	for name, body := range map[string]map[string]interface{}{
+		"the roles":      {"roles": []models.UserRole{models.Admin}},
+		"the attributes": {"attributes": map[string]string{"organization": "acme"}},
+		"the username":   {"username": "renamed"},
+		"the password":   {"password": "Qw7!xTr9kP"},
+	} {
+		res = s.request(http.MethodPatch, "/me", client.Token, body)
+		expectStatus(t, "changing "+name, res, http.StatusForbidden)
+	}
+	for name, body := range map[string]map[string]interface{}{
+		"an unknown field":    {"nickname": "pro"},
+		"an invalid email":    {"email": "not an email"},
+		"an invalid phone":    {"phone": "0812"},
+		"an invalid language": {"preferences": map[string]string{"language": "not a language"}},
+		"an invalid zone":     {"preferences": map[string]string{"timezone": "Mars/Olympus"}},
+		"an empty first name": {"first_name": ""},
+	} {
+		res = s.request(http.MethodPatch, "/me", client.Token, body)
+		expectStatus(t, "setting "+name, res, http.StatusBadRequest)
+	}
+
+	res = s.request(http.MethodGet, "/me", client.Token, nil)
+	res.decode(&me)
+	if len(me.Roles) != 1 || me.Roles[0] != models.General || me.Username != "profile" {
+		t.Errorf("the profile changed with the refused updates: %s", res.body)
+	}
+	s.login("profile", testPassword)
+
+	res = s.request(http.MethodPatch, "/me", "", map[string]interface{}{"last_name": "Lie"})
+	expectStatus(t, "updating without a token", res, http.StatusUnauthorized)
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"SejutaCita/handlers"
+	"SejutaCita/middleware"
+	"SejutaCita/models"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func RoleRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, roles models.RoleStore, store models.UserStore) {
+	handler := handlers.NewRoleHandler(l, roles, store)
+
+	register(r, table, []Route{
+		{Method: http.MethodGet, Path: "/roles", Access: middleware.Permissions(models.PermissionRolesRead), Handler: handler.GetRoles},
+		{
+			Method:  http.MethodGet,
+			Path:    "/role",
+			Queries: []string{"name", "{name}"},
+			Access:  middleware.Permissions(models.PermissionRolesRead),
+			Handler: handler.GetRole,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/role",
+			Access:      middleware.Permissions(models.PermissionRolesWrite),
+			Handler:     handler.CreateRole,
+			Middlewares: []mux.MiddlewareFunc{handler.MiddlewareValidateRole},
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/role",
+			Queries:     []string{"name", "{name}"},
+			Access:      middleware.Permissions(models.PermissionRolesWrite),
+			Handler:     handler.UpdateRole,
+			Middlewares: []mux.MiddlewareFunc{handler.MiddlewareValidateRole},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/role",
+			Queries: []string{"name", "{name}"},
+			Access:  middleware.Permissions(models.PermissionRolesWrite),
+			Handler: handler.DeleteRole,
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"net/http"
+	"testing"
+)
+
+// createSupport creates a Support role that manages users and roles without deleting users, and a user holding it
+func createSupport(t *testing.T, s *testServer) string {
+	t.Helper()
+
+	admin := s.login("admin", "admin")
+	res := s.request(http.MethodPost, "/role", admin.Token, map[string]interface{}{
+		"name":        "Support",
+		"description": "Manages users without deleting them",
+		"permissions": models.Permissions{models.PermissionUsersRead, models.PermissionUsersWrite, models.PermissionRolesWrite},
+	})
+	expectStatus(t, "creating the Support role", res, http.StatusOK)
+	return s.createUser("support", "Support")
+}
+
+func TestCreateRoleGrantsOnlyHeldPermissions(t *testing.T) {
+	s := newTestServer(t)
+	createSupport(t, s)
+	support := s.login("support", testPassword)
+
+	res := s.request(http.MethodPost, "/role", support.Token, map[string]interface{}{
+		"name":        "Janitor",
+		"permissions": models.Permissions{models.PermissionUsersDelete},
+	})
+	expectStatus(t, "granting a permission the client lacks", res, http.StatusForbidden)
+
+	res = s.request(http.MethodPost, "/role", support.Token, map[string]interface{}{
+		"name":        "Viewer",
+		"permissions": models.Permissions{models.PermissionUsersRead},
+	})
+	expectStatus(t, "granting a permission the client holds", res, http.StatusOK)
+
+	res = s.request(http.MethodPut, "/role?name=Viewer", support.Token, map[string]interface{}{
+		"permissions": models.Permissions{models.PermissionUsersRead, models.PermissionSessionsRevoke},
+	})
+	expectStatus(t, "widening a role beyond the client", res, http.StatusForbidden)
+
+	res = s.request(http.MethodPut, "/role?name="+string(models.Admin), support.Token, map[string]interface{}{
+		"permissions": models.Permissions{models.PermissionUsersRead},
+	})
+	expectStatus(t, "changing the Admin role", res, http.StatusConflict)
+}
+
+func TestRoleChangesApplyImmediately(t *testing.T) {
+	s := newTestServer(t)
+	createSupport(t, s)
+	admin := s.login("admin", "admin")
+	support := s.login("support", testPassword)
+
+	res := s.request(http.MethodGet, "/users", support.Token, nil)
+	expectStatus(t, "listing the users before the change", res, http.StatusOK)
+
+	res = s.request(http.MethodPut, "/role?name=Support", admin.Token, map[string]interface{}{
+		"permissions": models.Permissions{models.PermissionRolesWrite},
+	})
+	expectStatus(t, "narrowing the Support role", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/users", support.Token, nil)
+	expectStatus(t, "listing the users with the token issued before the change", res, http.StatusForbidden)
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"SejutaCita/middleware"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Route declares an endpoint and who is authorized to call it
+type Route struct {
+	Method string
+	Path   string
+	// the query parameters the route requires, as pairs of a key and a pattern
+	Queries []string
+	// who is authorized to call the route, enforced by the middleware of the AccessTable before any other middleware
+	Access  middleware.Access
+	Handler http.HandlerFunc
+	// the middlewares that run once the client is authorized, the first one runs first
+	Middlewares []mux.MiddlewareFunc
+}
+
+// register adds the routes to the router and declares their access in the table
+func register(r *mux.Router, table *middleware.AccessTable, routes []Route) {
+	for _, route := range routes {
+		var handler http.Handler = route.Handler
+		for i := len(route.Middlewares) - 1; i >= 0; i-- {
+			handler = route.Middlewares[i](handler)
+		}
+
+		muxRoute := r.Methods(route.Method).Path(route.Path)
+		if len(route.Queries) > 0 {
+			muxRoute = muxRoute.Queries(route.Queries...)
+		}
+		table.Declare(muxRoute.Handler(handler), route.Access)
+	}
+}
@@ -0,0 +1,189 @@
+package routes_test
+
+import (
+	"SejutaCita/middleware"
+	"SejutaCita/models"
+	"SejutaCita/routes"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testPassword is the password of the users created by the tests
+const testPassword = "Zq8#pLm2vR"
+
+// testServer runs the whole API on the in-memory stores, wired like main
+type testServer struct {
+	*httptest.Server
+	t            *testing.T
+	users        models.UserStore
+	tokens       models.TokenStore
+	keys         models.KeyStore
+	attempts     models.LoginAttemptStore
+	roles        models.RoleStore
+	accessTokens models.AccessTokenStore
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	// cheap password hashes keep the logins of the tests fast
+	t.Setenv("ARGON2_MEMORY", "8192")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+
+	users := models.NewMemoryUserStore()
+	err := models.SeedUsers(users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := models.NewMemoryTokenStore()
+	keys := models.NewMemoryKeyStore()
+	attempts := models.NewMemoryLoginAttemptStore()
+	roles := models.NewMemoryRoleStore()
+	accessTokens := models.NewMemoryAccessTokenStore()
+	err = models.SeedRoles(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = models.EnsureSigningKey(keys, models.SigningAlgorithm())
+	if err != nil {
+		t.Fatal(err)
+	}
+	models.UseKeyStore(keys)
+	models.UsePolicies(&models.PolicySet{})
+
+	l := log.New(io.Discard, "", 0)
+	r := mux.NewRouter()
+	table := middleware.NewAccessTable()
+	routes.AuthRoutes(r, l, table, users, tokens, attempts)
+	routes.UserRoutes(r, l, table, users, roles, tokens, accessTokens)
+	routes.SessionRoutes(r, l, table, tokens)
+	routes.MeRoutes(r, l, table, users, tokens, attempts, accessTokens)
+	routes.RoleRoutes(r, l, table, roles, users)
+	routes.AuthzRoutes(r, l, table, roles)
+	routes.AccessTokenRoutes(r, l, table, accessTokens)
+	r.Use(table.Middleware(tokens, roles, users, accessTokens))
+	err = table.Check(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		Server:       httptest.NewServer(r),
+		t:            t,
+		users:        users,
+		tokens:       tokens,
+		keys:         keys,
+		attempts:     attempts,
+		roles:        roles,
+		accessTokens: accessTokens,
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// testResponse is a response whose body was read
+type testResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// decode decodes the JSON body of the response into v
+func (res *testResponse) decode(v interface{}) {
+	err := json.Unmarshal(res.body, v)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// message returns the message of an error response
+func (res *testResponse) message() string {
+	body := models.GenericError{}
+	json.Unmarshal(res.body, &body)
+	return body.Message
+}
+
+// request sends the body as JSON with the bearer token, either of which may be empty
+func (s *testServer) request(method string, path string, token string, body interface{}, headers ...string) *testResponse {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return &testResponse{status: res.StatusCode, header: res.Header, body: data}
+}
+
+// login returns the tokens of the user, failing the test when the login fails
+func (s *testServer) login(username string, password string) models.UserToken {
+	s.t.Helper()
+
+	res := s.request(http.MethodPost, "/login", "", map[string]string{"username": username, "password": password})
+	if res.status != http.StatusOK {
+		s.t.Fatalf("login of %s: got status %d: %s", username, res.status, res.body)
+	}
+	tokens := models.UserToken{}
+	res.decode(&tokens)
+	return tokens
+}
+
+// createUser creates a user with the roles through the admin and returns its ID
+func (s *testServer) createUser(username string, roles ...models.UserRole) string {
+	s.t.Helper()
+
+	admin := s.login("admin", "admin")
+	res := s.request(http.MethodPost, "/user", admin.Token, map[string]interface{}{
+		"first_name": strings.Title(username),
+		"username":   username,
+		"password":   testPassword,
+		"roles":      roles,
+	})
+	if res.status != http.StatusOK {
+		s.t.Fatalf("creating user %s: got status %d: %s", username, res.status, res.body)
+	}
+	return string(res.body)
+}
+
+// expectStatus fails the test when the response does not have the status
+func expectStatus(t *testing.T, name string, res *testResponse, status int) {
+	t.Helper()
+
+	if res.status != status {
+		t.Errorf("%s: got status %d, want %d: %s", name, res.status, status, res.body)
+	}
+}
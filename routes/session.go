@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"SejutaCita/handlers"
+	"SejutaCita/middleware"
+	"SejutaCita/models"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func SessionRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, tokens models.TokenStore) {
+	handler := handlers.NewSessionHandler(l, tokens)
+
+	register(r, table, []Route{
+		// the own sessions of the client are not covered by any scope, so personal access tokens can not manage them
+		{Method: http.MethodGet, Path: "/sessions", Access: middleware.Authenticated().WithSession(), Handler: handler.GetSessions},
+		{
+			Method:  http.MethodGet,
+			Path:    "/user/sessions",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Permissions(models.PermissionSessionsRead).OnUser(),
+			Handler: handler.GetUserSessions,
+		},
+		// the owner of the session is only known once it is loaded, so the handler checks it
+		{
+			Method:  http.MethodDelete,
+			Path:    "/session",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Authenticated().WithSession(),
+			Handler: handler.RevokeSession,
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/user/sessions",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Permissions(models.PermissionSessionsRevoke).OnUser(),
+			Handler: handler.RevokeUserSessions,
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"net/http"
+	"testing"
+)
+
+func TestSessionLimitOfTheUser(t *testing.T) {
+	t.Setenv("MAX_SESSIONS_PER_USER", "3")
+	s := newTestServer(t)
+	id := s.createUser("capped", models.General)
+	admin := s.login("admin", "admin")
+
+	res := s.request(http.MethodPut, "/user?id="+id, admin.Token, map[string]int{"max_sessions": -1})
+	expectStatus(t, "a negative cap", res, http.StatusBadRequest)
+	res = s.request(http.MethodPut, "/user?id="+id, admin.Token, map[string]int{"max_sessions": 1})
+	expectStatus(t, "capping the sessions of the user", res, http.StatusOK)
+
+	first := s.login("capped", testPassword)
+	second := s.login("capped", testPassword)
+
+	res = s.request(http.MethodGet, "/user?id="+id, first.Token, nil)
+	expectStatus(t, "the session beyond the cap of the user", res, http.StatusUnauthorized)
+	res = s.request(http.MethodGet, "/sessions", second.Token, nil)
+	expectStatus(t, "listing the sessions", res, http.StatusOK)
+	sessions := models.Sessions{}
+	res.decode(&sessions)
+	if len(sessions) != 1 {
+		t.Errorf("got %d sessions, want 1", len(sessions))
+	}
+
+	// the other users keep the default of MAX_SESSIONS_PER_USER
+	general := s.login("general", "general")
+	s.login("general", "general")
+	res = s.request(http.MethodGet, "/sessions", general.Token, nil)
+	expectStatus(t, "a session within the default cap", res, http.StatusOK)
+}
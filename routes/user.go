@@ -3,45 +3,54 @@ package routes
 import (
 	"SejutaCita/handlers"
 	"SejutaCita/middleware"
+	"SejutaCita/models"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func UserRoutes(r *mux.Router, l *log.Logger) {
-	handler := handlers.NewUserHandler(l)
+func UserRoutes(r *mux.Router, l *log.Logger, table *middleware.AccessTable, store models.UserStore, roles models.RoleStore, tokens models.TokenStore, accessTokens models.AccessTokenStore) {
+	handler := handlers.NewUserHandler(l, store, roles, tokens, accessTokens)
 
-	getRouter := r.Methods(http.MethodGet).Subrouter()
-	getRouter.HandleFunc("/user", handler.GetUserById).
-		Queries(
-			"id", "{id}",
-		)
-	getRouter.HandleFunc("/users", handler.GetUsers).
-		Queries(
-			"role", "{role}",
-			"category", "{category}",
-			"order", "{order}",
-		)
-	getRouter.Use(middleware.Middleware)
-
-	postRouter := r.Methods(http.MethodPost).Subrouter()
-	postRouter.HandleFunc("/user", handler.CreateUser)
-	postRouter.Use(handler.MiddlewareValidateUser)
-	postRouter.Use(middleware.Middleware)
-
-	putRouter := r.Methods(http.MethodPut).Subrouter()
-	putRouter.HandleFunc("/user", handler.UpdateUser).
-		Queries(
-			"id", "{id}",
-		)
-	putRouter.Use(handler.MiddlewareValidateUser)
-	putRouter.Use(middleware.Middleware)
-
-	deleteRouter := r.Methods(http.MethodDelete).Subrouter()
-	deleteRouter.HandleFunc("/user", handler.DeleteUser).
-		Queries(
-			"id", "{id}",
-		)
-	deleteRouter.Use(middleware.Middleware)
+	register(r, table, []Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/user",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.OwnerOr(models.PermissionUsersRead).OnUser(),
+			Handler: handler.GetUserById,
+		},
+		{Method: http.MethodGet, Path: "/users", Access: middleware.Permissions(models.PermissionUsersRead).OnUsers(), Handler: handler.GetUsers},
+		{
+			Method:      http.MethodPost,
+			Path:        "/user",
+			Access:      middleware.Permissions(models.PermissionUsersWrite),
+			Handler:     handler.CreateUser,
+			Middlewares: []mux.MiddlewareFunc{handler.MiddlewareValidateUser},
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/user",
+			Queries:     []string{"id", "{id}"},
+			Access:      middleware.Permissions(models.PermissionUsersWrite).OnUser(),
+			Handler:     handler.UpdateUser,
+			Middlewares: []mux.MiddlewareFunc{handler.MiddlewareValidateUser},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/user",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Permissions(models.PermissionUsersDelete).OnUser(),
+			Handler: handler.DeleteUser,
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/user/restore",
+			Queries: []string{"id", "{id}"},
+			Access:  middleware.Permissions(models.PermissionUsersDelete).OnDeletedUser(),
+			Handler: handler.RestoreUser,
+		},
+		{Method: http.MethodPost, Path: "/users/purge", Access: middleware.Permissions(models.PermissionUsersDelete), Handler: handler.PurgeUsers},
+	})
 }
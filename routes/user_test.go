@@ -0,0 +1,374 @@
+package routes_test
+
+import (
+	"SejutaCita/models"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDeleteUserRefusesItsTokens(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	id := s.createUser("leaving", models.General)
+	leaving := s.login("leaving", testPassword)
+
+	res := s.request(http.MethodGet, "/user?id="+id, leaving.Token, nil)
+	expectStatus(t, "the token before the deletion", res, http.StatusOK)
+
+	res = s.request(http.MethodDelete, "/user?id="+id, admin.Token, nil)
+	expectStatus(t, "deleting the user", res, http.StatusOK)
+
+	res = s.request(http.MethodGet, "/user?id="+id, leaving.Token, nil)
+	expectStatus(t, "the token after the deletion", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": leaving.RefreshToken})
+	expectStatus(t, "refreshing after the deletion", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/login", "", map[string]string{"username": "leaving", "password": testPassword})
+	expectStatus(t, "logging in after the deletion", res, http.StatusUnauthorized)
+
+	// the tokens issued before the deletion stay refused once the user is restored
+	res = s.request(http.MethodPost, "/user/restore?id="+id, admin.Token, nil)
+	expectStatus(t, "restoring the user", res, http.StatusOK)
+	res = s.request(http.MethodGet, "/user?id="+id, leaving.Token, nil)
+	expectStatus(t, "the token after the restore", res, http.StatusUnauthorized)
+	res = s.request(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": leaving.RefreshToken})
+	expectStatus(t, "refreshing after the restore", res, http.StatusUnauthorized)
+}
+
+func TestRestoreAndPurgeUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	general := s.login("general", "general")
+	id := s.createUser("leaving", models.General)
+
+	res := s.request(http.MethodPost, "/user/restore?id="+id, admin.Token, nil)
+	expectStatus(t, "restoring a user that is not deleted", res, http.StatusConflict)
+
+	res = s.request(http.MethodDelete, "/user?id="+id, admin.Token, nil)
+	expectStatus(t, "deleting the user", res, http.StatusOK)
+	res = s.request(http.MethodPost, "/user", admin.Token, map[string]interface{}{
+		"first_name": "Leaving",
+		"username":   "leaving",
+		"password":   testPassword,
+		"roles":      []models.UserRole{models.General},
+	})
+	expectStatus(t, "taking the username of the deleted user", res, http.StatusConflict)
+
+	res = s.request(http.MethodPost, "/user/restore?id="+id, general.Token, nil)
+	expectStatus(t, "restoring as a General user", res, http.StatusForbidden)
+	res = s.request(http.MethodPost, "/user/restore?id="+id, admin.Token, nil)
+	expectStatus(t, "restoring the user", res, http.StatusOK)
+	s.login("leaving", testPassword)
+
+	// the user is purged once deleted for longer than the retention
+	res = s.request(http.MethodDelete, "/user?id="+id, admin.Token, nil)
+	expectStatus(t, "deleting the user again", res, http.StatusOK)
+	res = s.request(http.MethodPost, "/users/purge", admin.Token, nil)
+	expectStatus(t, "purging within the retention", res, http.StatusOK)
+	if string(res.body) != "0" {
+		t.Errorf("purging within the retention: got %s users purged", res.body)
+	}
+
+	t.Setenv("USER_RETENTION_DAYS", "0")
+	res = s.request(http.MethodPost, "/users/purge", general.Token, nil)
+	expectStatus(t, "purging as a General user", res, http.StatusForbidden)
+	res = s.request(http.MethodPost, "/users/purge", admin.Token, nil)
+	expectStatus(t, "purging past the retention", res, http.StatusOK)
+	if string(res.body) != "1" {
+		t.Errorf("purging past the retention: got %s users purged", res.body)
+	}
+	res = s.request(http.MethodPost, "/user/restore?id="+id, admin.Token, nil)
+	expectStatus(t, "restoring a purged user", res, http.StatusNotFound)
+}
+
+func TestCreateUserIgnoresStoredFields(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+
+	res := s.request(http.MethodPost, "/user", admin.Token, map[string]interface{}{
+		"first_name": "Ghost",
+		"username":   "ghost",
+		"password":   testPassword,
+		"roles":      []models.UserRole{models.General},
+		"deleted_at": "2020-01-01T00:00:00Z",
+		"email":      "not an email",
+	})
+	expectStatus(t, "creating a user with stored fields", res, http.StatusOK)
+	id := string(res.body)
+
+	res = s.request(http.MethodGet, "/user?id="+id, admin.Token, nil)
+	expectStatus(t, "getting the created user", res, http.StatusOK)
+	user := map[string]interface{}{}
+	res.decode(&user)
+	if user["deleted_at"] != nil || user["email"] != nil {
+		t.Errorf("the created user holds fields the client can not write: %s", res.body)
+	}
+}
+
+// linkNext returns the path in the Link header of the response that leads to the next page
+func linkNext(res *testResponse) string {
+	match := regexp.MustCompile(`^<([^>]+)>; rel="next"$`).FindStringSubmatch(res.header.Get("Link"))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func TestGetUsersPagination(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	for _, username := range []string{"erin", "bob", "dave", "carol", "alice"} {
+		s.createUser(username, models.General)
+	}
+
+	// the pages are followed through the Link header until the last page, which has neither a link nor a cursor
+	firstNames := []string{}
+	ids := map[string]bool{}
+	path := "/users?role=&category=first_name&order=2&limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages > 4 {
+			t.Fatalf("the pages never end, the last one was %s", path)
+		}
+
+		res := s.request(http.MethodGet, path, admin.Token, nil)
+		expectStatus(t, "getting "+path, res, http.StatusOK)
+		page := models.UserAdminViewsPage{}
+		res.decode(&page)
+		if len(page.Users) > 2 {
+			t.Errorf("getting %s: got %d users in a page of 2", path, len(page.Users))
+		}
+		for _, user := range page.Users {
+			if ids[user.Id.Hex()] {
+				t.Errorf("getting %s: user %s was already in a previous page", path, user.Username)
+			}
+			ids[user.Id.Hex()] = true
+			firstNames = append(firstNames, user.FirstName)
+		}
+
+		path = linkNext(res)
+		if (path == "") != (page.NextCursor == nil) {
+			t.Errorf("the Link header %q does not match the next cursor %v", res.header.Get("Link"), page.NextCursor)
+		}
+	}
+
+	if len(firstNames) != 7 {
+		t.Errorf("got %d users across the pages, want 7", len(firstNames))
+	}
+	if !sort.StringsAreSorted(firstNames) {
+		t.Errorf("the users across the pages are not sorted by first name: %v", firstNames)
+	}
+
+	res := s.request(http.MethodGet, "/users?role=&category=first_name&order=2&limit=2", admin.Token, nil)
+	page := models.UserAdminViewsPage{}
+	res.decode(&page)
+	res = s.request(http.MethodGet, "/users?role=&category=created_at&order=2&limit=2&cursor="+*page.NextCursor, admin.Token, nil)
+	expectStatus(t, "reusing a cursor with another sort", res, http.StatusBadRequest)
+	res = s.request(http.MethodGet, "/users?role=&category=first_name&order=2&cursor=invalid", admin.Token, nil)
+	expectStatus(t, "an invalid cursor", res, http.StatusBadRequest)
+	for _, limit := range []string{"0", "201", "many"} {
+		res = s.request(http.MethodGet, "/users?role=&category=first_name&order=2&limit="+limit, admin.Token, nil)
+		expectStatus(t, "a limit of "+limit, res, http.StatusBadRequest)
+	}
+}
+
+func TestGetUsersQueryParameters(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+
+	res := s.request(http.MethodGet, "/users", admin.Token, nil)
+	expectStatus(t, "getting users without parameters", res, http.StatusOK)
+	res = s.request(http.MethodGet, "/users?role=Admin,General&sort=-first_name&include_deleted=true&has_last_name=false", admin.Token, nil)
+	expectStatus(t, "getting users with valid parameters", res, http.StatusOK)
+
+	invalid := map[string]string{
+		"role":            "role=Admin,Owner",
+		"category":        "category=age",
+		"order":           "order=desc",
+		"sort":            "sort=first_name,-age",
+		"limit":           "limit=-1",
+		"include_deleted": "include_deleted=maybe",
+		"created_after":   "created_after=yesterday",
+		"updated_before":  "updated_before=2021-01-01",
+		"has_middle_name": "has_middle_name=yes",
+	}
+	for param, query := range invalid {
+		res := s.request(http.MethodGet, "/users?"+query, admin.Token, nil)
+		expectStatus(t, "getting users with "+query, res, http.StatusBadRequest)
+		if !strings.Contains(res.message(), param) {
+			t.Errorf("getting users with %s: the message %q does not name the parameter", query, res.message())
+		}
+	}
+}
+
+func TestUserPasswordPolicy(t *testing.T) {
+	t.Setenv("PASSWORD_CHARACTER_CLASSES", "lower,upper,digit,symbol")
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+
+	res := s.request(http.MethodPost, "/user", admin.Token, map[string]interface{}{
+		"first_name": "Weak",
+		"username":   "weak",
+		"password":   "weak",
+		"roles":      []models.UserRole{models.General},
+	})
+	expectStatus(t, "creating a user with a weak password", res, http.StatusBadRequest)
+	body := models.PasswordPolicyError{}
+	res.decode(&body)
+	rules := []string{}
+	for _, violation := range body.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	if body.Message != models.ErrPasswordPolicy.Error() || strings.Join(rules, ",") != "min_length,character_classes,personal_info" {
+		t.Errorf("creating a user with a weak password: got %s", res.body)
+	}
+
+	id := s.createUser("strong", models.General)
+	res = s.request(http.MethodPut, "/user?id="+id, admin.Token, map[string]interface{}{
+		"roles":    []models.UserRole{models.General},
+		"password": "Strong#2024",
+	})
+	expectStatus(t, "updating the password to one holding the username", res, http.StatusBadRequest)
+	res = s.request(http.MethodPut, "/user?id="+id, admin.Token, map[string]interface{}{
+		"roles":    []models.UserRole{models.General},
+		"password": "Qw7!xTr9kP",
+	})
+	expectStatus(t, "updating the password to one satisfying the policy", res, http.StatusOK)
+	s.login("strong", "Qw7!xTr9kP")
+}
+
+func TestUserManagementEscalation(t *testing.T) {
+	s := newTestServer(t)
+	createSupport(t, s)
+	generalId := s.createUser("someone", models.General)
+	admin := s.login("admin", "admin")
+	support := s.login("support", testPassword)
+
+	res := s.request(http.MethodPost, "/user", support.Token, map[string]interface{}{
+		"first_name": "Mallory",
+		"username":   "mallory",
+		"password":   testPassword,
+		"roles":      []models.UserRole{models.Admin},
+	})
+	expectStatus(t, "creating an Admin", res, http.StatusForbidden)
+
+	res = s.request(http.MethodPost, "/user", support.Token, map[string]interface{}{
+		"first_name": "Mallory",
+		"username":   "mallory",
+		"password":   testPassword,
+		"roles":      []models.UserRole{"Nope"},
+	})
+	expectStatus(t, "creating a user with an unknown role", res, http.StatusBadRequest)
+
+	res = s.request(http.MethodPut, "/user?id="+generalId, support.Token, map[string]interface{}{
+		"roles": []models.UserRole{models.Admin},
+	})
+	expectStatus(t, "promoting a user to Admin", res, http.StatusForbidden)
+
+	res = s.request(http.MethodGet, "/me", admin.Token, nil)
+	me := struct {
+		Id string `json:"id"`
+	}{}
+	res.decode(&me)
+	res = s.request(http.MethodPut, "/user?id="+me.Id, support.Token, map[string]interface{}{
+		"first_name": "Mallory",
+	})
+	expectStatus(t, "updating a more privileged user", res, http.StatusForbidden)
+
+	res = s.request(http.MethodPut, "/user?id="+generalId, support.Token, map[string]interface{}{
+		"first_name": "Someone",
+	})
+	expectStatus(t, "updating a less privileged user", res, http.StatusOK)
+}
+
+func TestGetUsersRejectsUnknownRoles(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+
+	res := s.request(http.MethodGet, "/users?role=General,Nope", admin.Token, nil)
+	expectStatus(t, "filtering on an unknown role", res, http.StatusBadRequest)
+	if res.message() != `invalid role: "Nope"` {
+		t.Errorf("filtering on an unknown role: got message %q", res.message())
+	}
+
+	res = s.request(http.MethodGet, "/users?role=General", admin.Token, nil)
+	expectStatus(t, "filtering on a known role", res, http.StatusOK)
+}
+
+func TestRestoreUserEscalation(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+	res := s.request(http.MethodPost, "/role", admin.Token, map[string]interface{}{
+		"name":        "Janitor",
+		"permissions": models.Permissions{models.PermissionUsersRead, models.PermissionUsersDelete},
+	})
+	expectStatus(t, "creating the Janitor role", res, http.StatusOK)
+	s.createUser("janitor", "Janitor")
+	janitor := s.login("janitor", testPassword)
+
+	ids := map[models.UserRole]string{
+		models.Admin:   s.createUser("boss", models.Admin),
+		models.General: s.createUser("someone", models.General),
+	}
+	for _, id := range ids {
+		res = s.request(http.MethodDelete, "/user?id="+id, admin.Token, nil)
+		expectStatus(t, "deleting a user", res, http.StatusOK)
+	}
+
+	res = s.request(http.MethodPost, "/user/restore?id="+ids[models.Admin], janitor.Token, nil)
+	expectStatus(t, "restoring a more privileged user", res, http.StatusForbidden)
+
+	res = s.request(http.MethodPost, "/user/restore?id="+ids[models.General], janitor.Token, nil)
+	expectStatus(t, "restoring a less privileged user", res, http.StatusOK)
+}
+
+func TestGetUserByIdOfTheOwner(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createUser("owner", models.General)
+	otherId := s.createUser("other", models.General)
+	owner := s.login("owner", testPassword)
+
+	res := s.request(http.MethodGet, "/user?id="+id, owner.Token, nil)
+	expectStatus(t, "getting the user of the client", res, http.StatusOK)
+	res = s.request(http.MethodGet, "/user?id="+otherId, owner.Token, nil)
+	expectStatus(t, "getting another user without users:read", res, http.StatusForbidden)
+	res = s.request(http.MethodGet, "/user?id="+id, "", nil)
+	expectStatus(t, "getting the user without a token", res, http.StatusUnauthorized)
+	res = s.request(http.MethodGet, "/users", owner.Token, nil)
+	expectStatus(t, "listing the users without users:read", res, http.StatusForbidden)
+}
+
+func TestRestoreUserAppliesResourcePolicies(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", "admin")
+
+	ids := map[string]string{}
+	for _, protected := range []string{"true", "false"} {
+		res := s.request(http.MethodPost, "/user", admin.Token, map[string]interface{}{
+			"first_name": "Protected",
+			"username":   "protected-" + protected,
+			"password":   testPassword,
+			"roles":      []models.UserRole{models.General},
+			"attributes": map[string]string{"protected": protected},
+		})
+		expectStatus(t, "creating a user", res, http.StatusOK)
+		ids[protected] = string(res.body)
+
+		res = s.request(http.MethodDelete, "/user?id="+ids[protected], admin.Token, nil)
+		expectStatus(t, "deleting the user", res, http.StatusOK)
+	}
+
+	models.UsePolicies(&models.PolicySet{Policies: []*models.Policy{{
+		Id:       "protected-users",
+		Effect:   models.PolicyDeny,
+		Actions:  []models.Permission{models.PermissionUsersDelete},
+		Resource: models.AttributeConditions{"attributes.protected": {In: []string{"true"}}},
+	}}})
+
+	res := s.request(http.MethodPost, "/user/restore?id="+ids["true"], admin.Token, nil)
+	expectStatus(t, "restoring a protected user", res, http.StatusForbidden)
+
+	res = s.request(http.MethodPost, "/user/restore?id="+ids["false"], admin.Token, nil)
+	expectStatus(t, "restoring an unprotected user", res, http.StatusOK)
+}